@@ -0,0 +1,229 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"yuque-spider-gui/internal/spider"
+)
+
+// accountsFile 账号配置文件名
+const accountsFile = "accounts.json"
+
+// Account 账号配置: 凭据、站点、默认下载配置与输出目录
+type Account struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// CredentialID 凭据库中保存的 Cookie
+	CredentialID string `json:"credentialId,omitempty"`
+	// BaseURL 站点地址, 如 https://www.yuque.com 或企业空间地址
+	BaseURL string `json:"baseUrl"`
+	// Config 该账号任务的默认配置
+	Config spider.Config `json:"config"`
+	// OutputPath 该账号任务的默认输出目录
+	OutputPath string    `json:"outputPath"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// ListAccounts 列出全部账号
+func (a *App) ListAccounts() ([]Account, error) {
+	accounts, err := loadAccounts()
+	if err != nil {
+		return nil, fmt.Errorf("读取账号失败: %w", err)
+	}
+	return accounts, nil
+}
+
+// SaveAccount 保存账号, ID 为空时新建. cookie 不为空时加密保存到凭据库, 返回账号 ID
+func (a *App) SaveAccount(account Account, cookie string) (string, error) {
+	account.Name = strings.TrimSpace(account.Name)
+	if account.Name == "" {
+		return "", fmt.Errorf("账号名称不能为空")
+	}
+	account.BaseURL = strings.TrimRight(strings.TrimSpace(account.BaseURL), "/")
+	if account.BaseURL == "" {
+		account.BaseURL = spider.DefaultBaseURL
+	}
+	if account.Config.Timeout == 0 {
+		account.Config = spider.DefaultConfig()
+	}
+	if err := spider.CheckNetworkConfig(account.Config); err != nil {
+		return "", fmt.Errorf("网络配置错误: %w", err)
+	}
+	if err := spider.CheckLayouts(account.Config.Layouts); err != nil {
+		return "", err
+	}
+
+	a.accountsMu.Lock()
+	defer a.accountsMu.Unlock()
+
+	accounts, err := loadAccounts()
+	if err != nil {
+		return "", fmt.Errorf("读取账号失败: %w", err)
+	}
+
+	index := -1
+	for i := range accounts {
+		if accounts[i].ID == account.ID {
+			index = i
+		}
+	}
+	now := time.Now()
+	if account.ID == "" {
+		buf := make([]byte, 6)
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		account.ID = "acct_" + hex.EncodeToString(buf)
+		account.CreatedAt = now
+	} else if index < 0 {
+		return "", fmt.Errorf("账号不存在: %s", account.ID)
+	} else {
+		account.CreatedAt = accounts[index].CreatedAt
+		// 凭据只能通过 cookie 参数更新
+		account.CredentialID = accounts[index].CredentialID
+	}
+	account.UpdatedAt = now
+
+	if cookie != "" {
+		if a.vault == nil {
+			return "", fmt.Errorf("凭据库不可用")
+		}
+		id, err := a.vault.Put(account.CredentialID, account.Name, cookie)
+		if err != nil {
+			return "", fmt.Errorf("保存凭据失败: %w", err)
+		}
+		account.CredentialID = id
+	}
+
+	if index < 0 {
+		accounts = append(accounts, account)
+	} else {
+		accounts[index] = account
+	}
+	if err := saveAccounts(accounts); err != nil {
+		return "", fmt.Errorf("保存账号失败: %w", err)
+	}
+	return account.ID, nil
+}
+
+// DeleteAccount 删除账号, 同时删除其凭据
+func (a *App) DeleteAccount(accountID string) error {
+	a.accountsMu.Lock()
+	defer a.accountsMu.Unlock()
+
+	accounts, err := loadAccounts()
+	if err != nil {
+		return fmt.Errorf("读取账号失败: %w", err)
+	}
+	for i, account := range accounts {
+		if account.ID != accountID {
+			continue
+		}
+		if account.CredentialID != "" && !credentialShared(accounts, account) {
+			if err := a.deleteCredential(account.CredentialID); err != nil {
+				return err
+			}
+		}
+		accounts = append(accounts[:i], accounts[i+1:]...)
+		if err := saveAccounts(accounts); err != nil {
+			return fmt.Errorf("保存账号失败: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("账号不存在: %s", accountID)
+}
+
+// credentialShared 是否有其他账号使用同一凭据
+func credentialShared(accounts []Account, account Account) bool {
+	for _, other := range accounts {
+		if other.ID != account.ID && other.CredentialID == account.CredentialID {
+			return true
+		}
+	}
+	return false
+}
+
+// AddTaskForAccount 使用账号的凭据、站点和默认配置添加任务.
+// bookURL 可以是完整地址, 也可以是相对账号站点的路径(如 group/book); outputPath 为空时使用账号的默认目录
+func (a *App) AddTaskForAccount(accountID, bookURL, outputPath string) (string, error) {
+	account, err := findAccount(accountID)
+	if err != nil {
+		return "", err
+	}
+
+	bookURL = strings.TrimSpace(bookURL)
+	if !strings.HasPrefix(bookURL, "http://") && !strings.HasPrefix(bookURL, "https://") {
+		bookURL = account.BaseURL + "/" + strings.TrimLeft(bookURL, "/")
+	}
+	if outputPath == "" {
+		outputPath = account.OutputPath
+	}
+	config := account.Config
+	config.BaseURL = account.BaseURL
+
+	taskID, err := a.AddTaskWithCredential(bookURL, account.CredentialID, outputPath, config)
+	if err != nil {
+		return "", err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tasks[taskID].AccountID = account.ID
+	a.emitTaskListUpdate()
+	return taskID, nil
+}
+
+// findAccount 按 ID 查找账号
+func findAccount(accountID string) (*Account, error) {
+	accounts, err := loadAccounts()
+	if err != nil {
+		return nil, fmt.Errorf("读取账号失败: %w", err)
+	}
+	for i := range accounts {
+		if accounts[i].ID == accountID {
+			return &accounts[i], nil
+		}
+	}
+	return nil, fmt.Errorf("账号不存在: %s", accountID)
+}
+
+// loadAccounts 读取账号配置
+func loadAccounts() ([]Account, error) {
+	dir, err := appConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, accountsFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Account{}, nil
+		}
+		return nil, err
+	}
+	var accounts []Account
+	if err := json.Unmarshal(data, &accounts); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// saveAccounts 写入账号配置
+func saveAccounts(accounts []Account) error {
+	dir, err := appConfigDir()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(accounts, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, accountsFile), data, 0600)
+}
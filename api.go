@@ -0,0 +1,394 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"yuque-spider-gui/internal/spider"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// apiSettingsFile 本地 API 设置文件名
+const apiSettingsFile = "api.json"
+
+// defaultAPIPort 本地 API 默认端口
+const defaultAPIPort = 17890
+
+// sseKeepAlive SSE 心跳间隔
+const sseKeepAlive = 15 * time.Second
+
+// APISettings 本地 HTTP API 设置
+type APISettings struct {
+	Enabled bool   `json:"enabled"`
+	Port    int    `json:"port"`
+	Token   string `json:"token"`
+}
+
+// APIStatus 本地 HTTP API 运行状态
+type APIStatus struct {
+	Running bool   `json:"running"`
+	Address string `json:"address,omitempty"`
+	Token   string `json:"token,omitempty"`
+}
+
+// apiEvent 推送给订阅者的事件
+type apiEvent struct {
+	Name string
+	Data []byte
+}
+
+// eventBroker 将任务事件广播给 SSE 订阅者
+type eventBroker struct {
+	mu   sync.Mutex
+	subs map[chan apiEvent]struct{}
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{subs: make(map[chan apiEvent]struct{})}
+}
+
+func (b *eventBroker) subscribe() chan apiEvent {
+	ch := make(chan apiEvent, 64)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch
+}
+
+func (b *eventBroker) unsubscribe(ch chan apiEvent) {
+	b.mu.Lock()
+	delete(b.subs, ch)
+	b.mu.Unlock()
+}
+
+// publish 序列化并广播事件, 订阅者处理不过来时丢弃, 不阻塞任务
+func (b *eventBroker) publish(name string, data any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.subs) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	for ch := range b.subs {
+		select {
+		case ch <- apiEvent{Name: name, Data: payload}:
+		default:
+		}
+	}
+}
+
+// emit 向前端发送事件, 同时推送给本地 API 的订阅者
+func (a *App) emit(name string, data any) {
+	runtime.EventsEmit(a.ctx, name, data)
+	a.events.publish(name, data)
+}
+
+// GetAPIStatus 获取本地 API 运行状态
+func (a *App) GetAPIStatus() APIStatus {
+	a.apiMu.Lock()
+	defer a.apiMu.Unlock()
+
+	if a.apiServer == nil {
+		return APIStatus{}
+	}
+	return APIStatus{Running: true, Address: "http://" + a.apiServer.Addr, Token: a.apiToken}
+}
+
+// StartAPIServer 在 localhost 上启动本地 API, port 为 0 时使用上次的端口, 设置会被保存并在下次启动时自动开启
+func (a *App) StartAPIServer(port int) (APIStatus, error) {
+	settings, err := loadAPISettings()
+	if err != nil {
+		return APIStatus{}, fmt.Errorf("读取 API 设置失败: %w", err)
+	}
+	if port != 0 {
+		settings.Port = port
+	}
+	settings.Enabled = true
+
+	if err := a.startAPIServer(settings); err != nil {
+		return APIStatus{}, err
+	}
+	if err := saveAPISettings(settings); err != nil {
+		return APIStatus{}, fmt.Errorf("保存 API 设置失败: %w", err)
+	}
+	return a.GetAPIStatus(), nil
+}
+
+// StopAPIServer 停止本地 API 并关闭自动开启
+func (a *App) StopAPIServer() error {
+	a.apiMu.Lock()
+	server := a.apiServer
+	a.apiServer = nil
+	a.apiMu.Unlock()
+
+	if server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}
+
+	settings, err := loadAPISettings()
+	if err != nil {
+		return fmt.Errorf("读取 API 设置失败: %w", err)
+	}
+	settings.Enabled = false
+	if err := saveAPISettings(settings); err != nil {
+		return fmt.Errorf("保存 API 设置失败: %w", err)
+	}
+	return nil
+}
+
+// RegenerateAPIToken 重新生成访问令牌, 旧令牌立即失效
+func (a *App) RegenerateAPIToken() (string, error) {
+	settings, err := loadAPISettings()
+	if err != nil {
+		return "", fmt.Errorf("读取 API 设置失败: %w", err)
+	}
+	if settings.Token, err = newAPIToken(); err != nil {
+		return "", fmt.Errorf("生成令牌失败: %w", err)
+	}
+	if err := saveAPISettings(settings); err != nil {
+		return "", fmt.Errorf("保存 API 设置失败: %w", err)
+	}
+
+	a.apiMu.Lock()
+	a.apiToken = settings.Token
+	a.apiMu.Unlock()
+	return settings.Token, nil
+}
+
+// startAPIServer 监听 127.0.0.1, 已在运行时先停止旧的服务
+func (a *App) startAPIServer(settings APISettings) error {
+	addr := net.JoinHostPort("127.0.0.1", fmt.Sprint(settings.Port))
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("监听 %s 失败: %w", addr, err)
+	}
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           a.apiHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	a.apiMu.Lock()
+	previous := a.apiServer
+	a.apiServer = server
+	a.apiToken = settings.Token
+	a.apiMu.Unlock()
+	if previous != nil {
+		previous.Close()
+	}
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("本地 API 已停止", "err", err)
+		}
+	}()
+	return nil
+}
+
+// apiHandler 本地 API 路由
+func (a *App) apiHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/tasks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, a.GetAllTasks())
+	})
+	mux.HandleFunc("POST /api/tasks", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			URL          string        `json:"url"`
+			Cookie       string        `json:"cookie"`
+			CredentialID string        `json:"credentialId"`
+			OutputPath   string        `json:"outputPath"`
+			Config       spider.Config `json:"config"`
+			Start        bool          `json:"start"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeAPIError(w, http.StatusBadRequest, fmt.Errorf("请求格式错误: %w", err))
+			return
+		}
+		if !a.ValidateURL(req.URL) {
+			writeAPIError(w, http.StatusBadRequest, fmt.Errorf("无效的知识库地址: %s", req.URL))
+			return
+		}
+		var taskID string
+		var err error
+		if req.CredentialID != "" {
+			taskID, err = a.AddTaskWithCredential(req.URL, req.CredentialID, req.OutputPath, req.Config)
+		} else {
+			taskID, err = a.AddTask(req.URL, req.Cookie, req.OutputPath, req.Config)
+		}
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, err)
+			return
+		}
+		if req.Start {
+			if err := a.StartTask(taskID); err != nil {
+				writeAPIError(w, http.StatusConflict, err)
+				return
+			}
+		}
+		writeJSON(w, http.StatusCreated, map[string]string{"id": taskID})
+	})
+	mux.HandleFunc("POST /api/tasks/{id}/start", func(w http.ResponseWriter, r *http.Request) {
+		a.serveTaskAction(w, a.StartTask(r.PathValue("id")))
+	})
+	mux.HandleFunc("POST /api/tasks/{id}/cancel", func(w http.ResponseWriter, r *http.Request) {
+		a.serveTaskAction(w, a.CancelTask(r.PathValue("id")))
+	})
+	mux.HandleFunc("DELETE /api/tasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		a.serveTaskAction(w, a.RemoveTask(r.PathValue("id")))
+	})
+	mux.HandleFunc("GET /api/events", a.serveEvents)
+
+	return a.requireToken(mux)
+}
+
+// requireToken 校验 Authorization: Bearer 令牌, EventSource 无法设置请求头时可使用 ?token=
+func (a *App) requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" {
+			token = r.URL.Query().Get("token")
+		}
+
+		a.apiMu.Lock()
+		expected := a.apiToken
+		a.apiMu.Unlock()
+
+		if expected == "" || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+			writeAPIError(w, http.StatusUnauthorized, fmt.Errorf("令牌无效"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// serveTaskAction 返回任务操作的结果
+func (a *App) serveTaskAction(w http.ResponseWriter, err error) {
+	if err != nil {
+		status := http.StatusConflict
+		if strings.HasPrefix(err.Error(), "任务不存在") {
+			status = http.StatusNotFound
+		}
+		writeAPIError(w, status, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveEvents 以 Server-Sent Events 推送 task:update 与 tasks:update 事件
+func (a *App) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeAPIError(w, http.StatusInternalServerError, fmt.Errorf("不支持事件流"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	events := a.events.subscribe()
+	defer a.events.unsubscribe(events)
+
+	// 连接后先推送当前任务列表
+	snapshot, _ := json.Marshal(a.GetAllTasks())
+	fmt.Fprintf(w, "event: tasks:update\ndata: %s\n\n", snapshot)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-events:
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Name, event.Data)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// newAPIToken 生成随机访问令牌
+func newAPIToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// loadAPISettings 读取 API 设置, 首次使用时生成令牌
+func loadAPISettings() (APISettings, error) {
+	settings := APISettings{Port: defaultAPIPort}
+	dir, err := appConfigDir()
+	if err != nil {
+		return settings, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, apiSettingsFile))
+	if err != nil && !os.IsNotExist(err) {
+		return settings, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &settings); err != nil {
+			return settings, err
+		}
+	}
+
+	if settings.Port == 0 {
+		settings.Port = defaultAPIPort
+	}
+	if settings.Token == "" {
+		if settings.Token, err = newAPIToken(); err != nil {
+			return settings, err
+		}
+	}
+	return settings, nil
+}
+
+// saveAPISettings 保存 API 设置, 包含令牌, 仅当前用户可读
+func saveAPISettings(settings APISettings) error {
+	dir, err := appConfigDir()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, apiSettingsFile), data, 0600)
+}
@@ -3,12 +3,16 @@ package main
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
 	"path/filepath"
 	"sync"
 	"time"
 
+	"yuque-spider-gui/internal/search"
 	"yuque-spider-gui/internal/spider"
+	"yuque-spider-gui/internal/vault"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
@@ -19,6 +23,7 @@ type TaskStatus string
 const (
 	TaskStatusPending    TaskStatus = "pending"
 	TaskStatusRunning    TaskStatus = "running"
+	TaskStatusPaused     TaskStatus = "paused" // 登录失效, 等待新的凭据
 	TaskStatusCompleted  TaskStatus = "completed"
 	TaskStatusFailed     TaskStatus = "failed"
 	TaskStatusCancelled  TaskStatus = "cancelled"
@@ -28,7 +33,9 @@ const (
 type DownloadTaskItem struct {
 	ID          string                   `json:"id"`
 	URL         string                   `json:"url"`
-	Cookie      string                   `json:"cookie"`
+	Cookie      string                   `json:"-"`                      // 直接填写的 Cookie, 不发送给前端
+	CredentialID string                  `json:"credentialId,omitempty"` // 引用的凭据
+	AccountID   string                   `json:"accountId,omitempty"`    // 所属账号
 	OutputPath  string                   `json:"outputPath"`
 	Config      spider.Config            `json:"config"`
 	Status      TaskStatus               `json:"status"`
@@ -37,6 +44,9 @@ type DownloadTaskItem struct {
 	CreatedAt   time.Time                `json:"createdAt"`
 	StartedAt   *time.Time               `json:"startedAt,omitempty"`
 	CompletedAt *time.Time               `json:"completedAt,omitempty"`
+	Schedule    string                   `json:"schedule,omitempty"`  // 定时同步表达式
+	NextRunAt   *time.Time               `json:"nextRunAt,omitempty"` // 下次定时运行时间
+	History     []RunRecord              `json:"history,omitempty"`   // 运行记录
 	cancelFunc  context.CancelFunc
 	spider      *spider.Spider
 }
@@ -48,6 +58,14 @@ type App struct {
 	taskOrder []string // 保持任务顺序
 	mu        sync.RWMutex
 	taskIDCounter int
+	events    *eventBroker // 本地 API 的事件订阅
+	apiMu     sync.Mutex
+	apiServer *http.Server
+	apiToken  string
+	hooks     hookLog // 任务钩子执行记录
+	vault     *vault.Vault
+	searchIndex *search.Index // 已下载知识库的全文索引
+	accountsMu sync.Mutex
 }
 
 // NewApp creates a new App application struct
@@ -55,6 +73,7 @@ func NewApp() *App {
 	return &App{
 		tasks:     make(map[string]*DownloadTaskItem),
 		taskOrder: make([]string, 0),
+		events:    newEventBroker(),
 	}
 }
 
@@ -62,6 +81,27 @@ func NewApp() *App {
 // so we can call the runtime methods
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
+	a.openVault()
+	a.openSearchIndex()
+	pruneTaskLogs()
+
+	// 全局限速变化时通知前端
+	spider.SetRateLimitListener(func(rate spider.HostRate) {
+		a.emit("ratelimit:update", rate)
+	})
+
+	// 恢复定时任务并启动调度
+	if err := a.loadScheduledTasks(); err != nil {
+		slog.Error("加载定时任务失败", "err", err)
+	}
+	go a.runScheduler(ctx)
+
+	// 按上次的设置开启本地 API
+	if settings, err := loadAPISettings(); err == nil && settings.Enabled {
+		if err := a.startAPIServer(settings); err != nil {
+			slog.Error("启动本地 API 失败", "err", err)
+		}
+	}
 }
 
 // GetDefaultConfig 获取默认配置
@@ -69,6 +109,74 @@ func (a *App) GetDefaultConfig() spider.Config {
 	return spider.DefaultConfig()
 }
 
+// GetRateLimits 获取各站点当前的全局限速状态
+func (a *App) GetRateLimits() []spider.HostRate {
+	return spider.RateLimits()
+}
+
+// GetSupportedLayouts 获取支持的静态站点布局
+func (a *App) GetSupportedLayouts() []string {
+	return spider.LayoutNames()
+}
+
+// GetSupportedTransforms 获取支持的 Markdown 后处理
+func (a *App) GetSupportedTransforms() []string {
+	return spider.TransformNames()
+}
+
+// GetSupportedIndexFormats 获取支持的索引格式
+func (a *App) GetSupportedIndexFormats() []string {
+	return spider.IndexFormatNames()
+}
+
+// ListDocumentHistory 列出任务中某个文档已保存的历史版本, docPath 为文档相对知识库目录的路径
+func (a *App) ListDocumentHistory(taskID, docPath string) ([]spider.HistoryVersion, error) {
+	bookDir, err := a.taskBookDir(taskID)
+	if err != nil {
+		return nil, err
+	}
+	return spider.ReadHistory(bookDir, docPath)
+}
+
+// DiffDocumentFiles 比较任务知识库中的两个文件(历史版本或当前文档), 返回统一格式差异
+func (a *App) DiffDocumentFiles(taskID, oldPath, newPath string) (string, error) {
+	bookDir, err := a.taskBookDir(taskID)
+	if err != nil {
+		return "", err
+	}
+	return spider.DiffFiles(bookDir, oldPath, newPath)
+}
+
+// GetChangeReport 获取任务最近一次同步的变更报告
+func (a *App) GetChangeReport(taskID string) (*spider.ChangeReport, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	task, exists := a.tasks[taskID]
+	if !exists {
+		return nil, fmt.Errorf("任务不存在: %s", taskID)
+	}
+	if task.spider == nil || task.spider.ChangeReport() == nil {
+		return nil, fmt.Errorf("没有可用的变更报告")
+	}
+	return task.spider.ChangeReport(), nil
+}
+
+// taskBookDir 返回任务对应的知识库目录
+func (a *App) taskBookDir(taskID string) (string, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	task, exists := a.tasks[taskID]
+	if !exists {
+		return "", fmt.Errorf("任务不存在: %s", taskID)
+	}
+	if task.Progress.BookDir == "" {
+		return "", fmt.Errorf("任务尚未下载")
+	}
+	return task.Progress.BookDir, nil
+}
+
 // SelectDirectory 选择目录
 func (a *App) SelectDirectory() (string, error) {
 	dir, err := runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{
@@ -96,6 +204,12 @@ func (a *App) AddTask(url, cookie, outputPath string, config spider.Config) (str
 	if config.Timeout == 0 {
 		config = spider.DefaultConfig()
 	}
+	if err := spider.CheckNetworkConfig(config); err != nil {
+		return "", fmt.Errorf("网络配置错误: %w", err)
+	}
+	if err := spider.CheckLayouts(config.Layouts); err != nil {
+		return "", err
+	}
 
 	// 创建任务
 	task := &DownloadTaskItem{
@@ -131,7 +245,7 @@ func (a *App) RemoveTask(taskID string) error {
 	}
 
 	// 如果任务正在运行，先取消
-	if task.Status == TaskStatusRunning && task.cancelFunc != nil {
+	if task.active() && task.cancelFunc != nil {
 		task.cancelFunc()
 	}
 
@@ -146,12 +260,24 @@ func (a *App) RemoveTask(taskID string) error {
 		}
 	}
 
+	// 同步删除持久化的定时任务
+	if task.Schedule != "" {
+		if err := a.saveScheduledTasks(); err != nil {
+			slog.Error("保存定时任务失败", "err", err)
+		}
+	}
+
 	a.emitTaskListUpdate()
 	return nil
 }
 
 // StartTask 开始任务
 func (a *App) StartTask(taskID string) error {
+	return a.startTask(taskID, TriggerManual)
+}
+
+// startTask 启动任务, 定时触发时使用增量同步
+func (a *App) startTask(taskID, trigger string) error {
 	a.mu.Lock()
 	task, exists := a.tasks[taskID]
 	if !exists {
@@ -159,7 +285,7 @@ func (a *App) StartTask(taskID string) error {
 		return fmt.Errorf("任务不存在: %s", taskID)
 	}
 
-	if task.Status == TaskStatusRunning {
+	if task.active() {
 		a.mu.Unlock()
 		return fmt.Errorf("任务正在运行中")
 	}
@@ -170,18 +296,30 @@ func (a *App) StartTask(taskID string) error {
 		return fmt.Errorf("创建输出目录失败: %w", err)
 	}
 
+	cookie, err := a.taskCookie(task)
+	if err != nil {
+		a.mu.Unlock()
+		return err
+	}
+
 	// 更新任务状态
 	task.Status = TaskStatusRunning
 	now := time.Now()
 	task.StartedAt = &now
 	task.Error = ""
+	a.beginRun(task, trigger)
+
+	config := task.Config
+	if trigger == TriggerSchedule {
+		config.Incremental = true
+	}
 
 	// 创建上下文
 	ctx, cancel := context.WithCancel(a.ctx)
 	task.cancelFunc = cancel
 
 	// 创建爬虫实例
-	task.spider = spider.NewSpider(task.Cookie, task.OutputPath, task.Config, func(progress spider.DownloadProgress) {
+	task.spider = spider.NewSpider(cookie, task.OutputPath, config, func(progress spider.DownloadProgress) {
 		a.mu.Lock()
 		defer a.mu.Unlock()
 
@@ -189,7 +327,13 @@ func (a *App) StartTask(taskID string) error {
 			t.Progress = progress
 
 			// 检查是否完成
-			if progress.Status == "completed" {
+			if progress.Status == "paused" {
+				// 登录失效, 通知前端提供新的凭据
+				t.Status = TaskStatusPaused
+				a.emit("task:session-expired", t)
+			} else if progress.Status == "downloading" && t.Status == TaskStatusPaused {
+				t.Status = TaskStatusRunning
+			} else if progress.Status == "completed" {
 				t.Status = TaskStatusCompleted
 				now := time.Now()
 				t.CompletedAt = &now
@@ -205,35 +349,66 @@ func (a *App) StartTask(taskID string) error {
 			}
 
 			// 发送任务更新事件
-			runtime.EventsEmit(a.ctx, "task:update", t)
+			a.emit("task:update", t)
 		}
 	})
 
+	// 任务日志写入文件并推送给前端, 打开失败时使用默认日志
+	logger, logFile, err := a.openTaskLog(task)
+	if err != nil {
+		slog.Error("打开任务日志失败", "task", taskID, "err", err)
+		logger = slog.Default().With("task", taskID)
+	} else {
+		task.spider.SetLogger(logger)
+		logger.Info("任务启动", "trigger", trigger)
+	}
+
 	a.mu.Unlock()
 
 	// 在后台启动下载
 	go func() {
+		if logFile != nil {
+			defer logFile.Close()
+		}
+
 		downloadTask := spider.DownloadTask{
 			URL:        task.URL,
-			Cookie:     task.Cookie,
+			Cookie:     cookie,
 			OutputPath: task.OutputPath,
-			Config:     task.Config,
+			Config:     config,
 		}
 
 		err := task.spider.Download(ctx, downloadTask)
 
+		var hooks *HookPayload
 		a.mu.Lock()
-		defer a.mu.Unlock()
-
 		if t, ok := a.tasks[taskID]; ok {
-			if err != nil && t.Status == TaskStatusRunning {
+			if err != nil && t.active() {
 				t.Status = TaskStatusFailed
 				t.Error = err.Error()
 				now := time.Now()
 				t.CompletedAt = &now
-				runtime.EventsEmit(a.ctx, "task:update", t)
+				a.emit("task:update", t)
+			}
+			a.finishRun(t)
+
+			// 任务结束钩子在释放锁后执行
+			if t.Status == TaskStatusCompleted || t.Status == TaskStatusFailed {
+				payload := newHookPayload(t)
+				hooks = &payload
+			}
+
+			// 更新搜索索引
+			if t.Status == TaskStatusCompleted {
+				go a.indexBook(t.Progress.BookDir)
 			}
 		}
+		a.mu.Unlock()
+
+		// 钩子结果写入任务日志, 执行完后才关闭日志文件
+		if hooks != nil {
+			a.runHooks(*hooks, logger)
+		}
 	}()
 
 	return nil
@@ -249,7 +424,7 @@ func (a *App) CancelTask(taskID string) error {
 		return fmt.Errorf("任务不存在: %s", taskID)
 	}
 
-	if task.Status != TaskStatusRunning {
+	if !task.active() {
 		return fmt.Errorf("任务未在运行中")
 	}
 
@@ -258,7 +433,7 @@ func (a *App) CancelTask(taskID string) error {
 		task.Status = TaskStatusCancelled
 		now := time.Now()
 		task.CompletedAt = &now
-		runtime.EventsEmit(a.ctx, "task:update", task)
+		a.emit("task:update", task)
 	}
 
 	return nil
@@ -283,7 +458,7 @@ func (a *App) GetAllTasks() []DownloadTaskItem {
 	return tasks
 }
 
-// ClearCompletedTasks 清除已完成的任务
+// ClearCompletedTasks 清除已完成的任务, 定时任务保留
 func (a *App) ClearCompletedTasks() error {
 	a.mu.Lock()
 	defer a.mu.Unlock()
@@ -291,7 +466,7 @@ func (a *App) ClearCompletedTasks() error {
 	newTaskOrder := make([]string, 0)
 	for _, taskID := range a.taskOrder {
 		task := a.tasks[taskID]
-		if task.Status == TaskStatusCompleted || task.Status == TaskStatusFailed || task.Status == TaskStatusCancelled {
+		if task.Schedule == "" && (task.Status == TaskStatusCompleted || task.Status == TaskStatusFailed || task.Status == TaskStatusCancelled) {
 			delete(a.tasks, taskID)
 		} else {
 			newTaskOrder = append(newTaskOrder, taskID)
@@ -341,7 +516,7 @@ func (a *App) emitTaskListUpdate() {
 			tasks = append(tasks, taskCopy)
 		}
 	}
-	runtime.EventsEmit(a.ctx, "tasks:update", tasks)
+	a.emit("tasks:update", tasks)
 }
 
 func contains(s, substr string) bool {
@@ -356,3 +531,8 @@ func containsSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+// active 任务正在运行或暂停等待凭据
+func (t *DownloadTaskItem) active() bool {
+	return t.Status == TaskStatusRunning || t.Status == TaskStatusPaused
+}
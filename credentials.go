@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"yuque-spider-gui/internal/spider"
+	"yuque-spider-gui/internal/vault"
+)
+
+// CookieImportResult Cookie 文件导入结果
+type CookieImportResult struct {
+	CredentialID string   `json:"credentialId"`
+	Count        int      `json:"count"`
+	Names        []string `json:"names"`
+}
+
+// openVault 打开配置目录中的凭据库
+func (a *App) openVault() {
+	dir, err := appConfigDir()
+	if err != nil {
+		slog.Error("打开凭据库失败", "err", err)
+		return
+	}
+	// 钥匙串读取失败时仍返回凭据库, 此时处于未解锁状态
+	v, err := vault.Open(dir)
+	if err != nil {
+		slog.Warn("打开凭据库失败", "err", err)
+	}
+	a.vault = v
+}
+
+// GetVaultStatus 获取凭据库状态
+func (a *App) GetVaultStatus() vault.Status {
+	if a.vault == nil {
+		return vault.Status{}
+	}
+	return a.vault.Status()
+}
+
+// UnlockVault 以口令解锁凭据库, 首次使用时设置口令
+func (a *App) UnlockVault(passphrase string) error {
+	if a.vault == nil {
+		return fmt.Errorf("凭据库不可用")
+	}
+	if err := a.vault.Unlock(passphrase); err != nil {
+		return fmt.Errorf("解锁凭据库失败: %w", err)
+	}
+
+	// 解锁后迁移定时任务中尚未加密的 Cookie
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.secureTaskCookies() {
+		if err := a.saveScheduledTasks(); err != nil {
+			slog.Error("保存定时任务失败", "err", err)
+		}
+	}
+	return nil
+}
+
+// ResetVault 系统钥匙串丢失等原因导致凭据库无法解锁时, 丢弃已保存的凭据并重建凭据库.
+// passphrase 非空时改用口令保护, 为空时重新使用系统钥匙串
+func (a *App) ResetVault(passphrase string) error {
+	if a.vault == nil {
+		return fmt.Errorf("凭据库不可用")
+	}
+	if err := a.vault.Reset(passphrase); err != nil {
+		return fmt.Errorf("重置凭据库失败: %w", err)
+	}
+	return nil
+}
+
+// LockVault 锁定凭据库, 仅口令模式有效
+func (a *App) LockVault() {
+	if a.vault != nil {
+		a.vault.Lock()
+	}
+}
+
+// ListCredentials 列出已保存的凭据, 不含凭据内容
+func (a *App) ListCredentials() []vault.Credential {
+	if a.vault == nil {
+		return []vault.Credential{}
+	}
+	return a.vault.List()
+}
+
+// SaveCredential 保存凭据, id 为空时新建, 返回凭据 ID
+func (a *App) SaveCredential(id, name, secret string) (string, error) {
+	if a.vault == nil {
+		return "", fmt.Errorf("凭据库不可用")
+	}
+	if name == "" {
+		return "", fmt.Errorf("凭据名称不能为空")
+	}
+	id, err := a.vault.Put(id, name, secret)
+	if err != nil {
+		return "", fmt.Errorf("保存凭据失败: %w", err)
+	}
+	return id, nil
+}
+
+// RenameCredential 修改凭据名称
+func (a *App) RenameCredential(id, name string) error {
+	if a.vault == nil {
+		return fmt.Errorf("凭据库不可用")
+	}
+	if err := a.vault.Rename(id, name); err != nil {
+		return fmt.Errorf("修改凭据失败: %w", err)
+	}
+	return nil
+}
+
+// DeleteCredential 删除凭据, 仍被账号或任务引用时拒绝删除
+func (a *App) DeleteCredential(id string) error {
+	a.accountsMu.Lock()
+	defer a.accountsMu.Unlock()
+
+	accounts, err := loadAccounts()
+	if err != nil {
+		return fmt.Errorf("读取账号失败: %w", err)
+	}
+	for _, account := range accounts {
+		if account.CredentialID == id {
+			return fmt.Errorf("凭据正在被账号使用: %s", account.Name)
+		}
+	}
+	return a.deleteCredential(id)
+}
+
+// deleteCredential 删除凭据, 仍被等待中、运行中或定时任务引用时拒绝删除.
+// 已结束的普通任务不阻止删除, 重新运行时会提示凭据不存在
+func (a *App) deleteCredential(id string) error {
+	if a.vault == nil {
+		return fmt.Errorf("凭据库不可用")
+	}
+
+	a.mu.RLock()
+	for _, task := range a.tasks {
+		if task.CredentialID != id {
+			continue
+		}
+		if task.Schedule != "" || task.Status == TaskStatusPending || task.active() {
+			a.mu.RUnlock()
+			return fmt.Errorf("凭据正在被任务使用: %s", task.ID)
+		}
+	}
+	a.mu.RUnlock()
+
+	if err := a.vault.Delete(id); err != nil {
+		return fmt.Errorf("删除凭据失败: %w", err)
+	}
+	return nil
+}
+
+// SelectCookieFile 选择浏览器导出的 cookies.txt 或 HAR 文件
+func (a *App) SelectCookieFile() (string, error) {
+	return runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: "选择 Cookie 文件",
+		Filters: []runtime.FileFilter{
+			{DisplayName: "Cookie 文件 (*.txt;*.har)", Pattern: "*.txt;*.har"},
+			{DisplayName: "所有文件", Pattern: "*"},
+		},
+	})
+}
+
+// ImportCredentialFile 从 Netscape 格式 cookies.txt 或 HAR 文件导入语雀 Cookie 并保存为凭据.
+// id 不为空时更新该凭据; name 为空时使用文件名
+func (a *App) ImportCredentialFile(id, name, path string) (*CookieImportResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 Cookie 文件失败: %w", err)
+	}
+
+	var cookies []*http.Cookie
+	trimmed := bytes.TrimSpace(data)
+	if strings.EqualFold(filepath.Ext(path), ".har") || bytes.HasPrefix(trimmed, []byte("{")) {
+		cookies, err = spider.ParseHARCookies(data)
+	} else {
+		cookies, err = spider.ParseNetscapeCookies(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("导入 Cookie 失败: %w", err)
+	}
+
+	secret, err := spider.EncodeCookies(cookies)
+	if err != nil {
+		return nil, fmt.Errorf("导入 Cookie 失败: %w", err)
+	}
+	if name = strings.TrimSpace(name); name == "" {
+		name = filepath.Base(path)
+	}
+	id, err = a.SaveCredential(id, name, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CookieImportResult{CredentialID: id, Count: len(cookies)}
+	for _, cookie := range cookies {
+		result.Names = append(result.Names, cookie.Name)
+	}
+	return result, nil
+}
+
+// AddTaskWithCredential 使用已保存的凭据添加任务
+func (a *App) AddTaskWithCredential(url, credentialID, outputPath string, config spider.Config) (string, error) {
+	if credentialID != "" && !a.credentialExists(credentialID) {
+		return "", fmt.Errorf("凭据不存在: %s", credentialID)
+	}
+
+	taskID, err := a.AddTask(url, "", outputPath, config)
+	if err != nil {
+		return "", err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tasks[taskID].CredentialID = credentialID
+	a.emitTaskListUpdate()
+	return taskID, nil
+}
+
+// credentialExists 凭据库中是否有该凭据
+func (a *App) credentialExists(id string) bool {
+	for _, credential := range a.ListCredentials() {
+		if credential.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// taskCookie 返回任务使用的 Cookie, 引用凭据时从凭据库解密, 调用方需持有锁
+func (a *App) taskCookie(task *DownloadTaskItem) (string, error) {
+	if task.CredentialID == "" {
+		return task.Cookie, nil
+	}
+	if a.vault == nil {
+		return "", fmt.Errorf("凭据库不可用")
+	}
+	cookie, err := a.vault.Get(task.CredentialID)
+	if err != nil {
+		return "", fmt.Errorf("读取凭据失败: %w", err)
+	}
+	return cookie, nil
+}
+
+// secureTaskCookie 将定时任务中直接填写的 Cookie 转存到凭据库, 调用方需持有锁.
+// 只有需要写入磁盘的定时任务才转存; 普通任务的 Cookie 只保存在内存中, 不写入磁盘也不发送给前端,
+// 随任务删除或程序退出而丢弃, 避免为一次性任务在凭据库中留下无人管理的凭据
+func (a *App) secureTaskCookie(task *DownloadTaskItem) error {
+	if task.Cookie == "" || task.CredentialID != "" {
+		return nil
+	}
+	if a.vault == nil {
+		return fmt.Errorf("凭据库不可用")
+	}
+	id, err := a.vault.Put("", task.URL, task.Cookie)
+	if err != nil {
+		return err
+	}
+	task.CredentialID = id
+	task.Cookie = ""
+	return nil
+}
+
+// secureTaskCookies 转存全部定时任务的 Cookie(普通任务不转存, 见 secureTaskCookie), 返回是否有任务被修改, 调用方需持有锁
+func (a *App) secureTaskCookies() bool {
+	changed := false
+	for _, task := range a.tasks {
+		if task.Schedule == "" || task.Cookie == "" || task.CredentialID != "" {
+			continue
+		}
+		if err := a.secureTaskCookie(task); err == nil {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// ValidateCookie 校验 Cookie 的登录状态, bookURL 不为空时同时检查知识库访问权限, baseURL 为空时使用知识库所在站点
+func (a *App) ValidateCookie(cookie, bookURL, baseURL string) *spider.SessionInfo {
+	config := spider.DefaultConfig()
+	config.BaseURL = baseURL
+	return spider.ValidateSession(cookie, bookURL, config)
+}
+
+// ValidateCookieWithConfig 使用任务配置中的站点、代理和证书校验 Cookie
+func (a *App) ValidateCookieWithConfig(cookie, bookURL string, config spider.Config) *spider.SessionInfo {
+	if config.Timeout == 0 {
+		config.Timeout = spider.DefaultConfig().Timeout
+	}
+	return spider.ValidateSession(cookie, bookURL, config)
+}
+
+// ValidateCredential 校验已保存凭据的登录状态
+func (a *App) ValidateCredential(credentialID, bookURL, baseURL string) (*spider.SessionInfo, error) {
+	if a.vault == nil {
+		return nil, fmt.Errorf("凭据库不可用")
+	}
+	cookie, err := a.vault.Get(credentialID)
+	if err != nil {
+		return nil, fmt.Errorf("读取凭据失败: %w", err)
+	}
+	return a.ValidateCookie(cookie, bookURL, baseURL), nil
+}
+
+// ResumeTask 使用新的 Cookie 继续因登录失效而暂停的任务, 任务引用凭据时同时更新凭据库
+func (a *App) ResumeTask(taskID, cookie string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	task, exists := a.tasks[taskID]
+	if !exists {
+		return fmt.Errorf("任务不存在: %s", taskID)
+	}
+	if task.Status != TaskStatusPaused || task.spider == nil {
+		return fmt.Errorf("任务未在等待新的凭据")
+	}
+
+	if task.CredentialID != "" {
+		if err := a.updateCredentialSecret(task.CredentialID, cookie); err != nil {
+			return err
+		}
+	} else {
+		task.Cookie = cookie
+	}
+	return task.spider.Resume(cookie)
+}
+
+// ResumeTaskWithCredential 改用另一个已保存的凭据继续暂停的任务
+func (a *App) ResumeTaskWithCredential(taskID, credentialID string) error {
+	if a.vault == nil {
+		return fmt.Errorf("凭据库不可用")
+	}
+	cookie, err := a.vault.Get(credentialID)
+	if err != nil {
+		return fmt.Errorf("读取凭据失败: %w", err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	task, exists := a.tasks[taskID]
+	if !exists {
+		return fmt.Errorf("任务不存在: %s", taskID)
+	}
+	if task.Status != TaskStatusPaused || task.spider == nil {
+		return fmt.Errorf("任务未在等待新的凭据")
+	}
+	task.CredentialID = credentialID
+	task.Cookie = ""
+	return task.spider.Resume(cookie)
+}
+
+// updateCredentialSecret 更新凭据内容, 保留名称
+func (a *App) updateCredentialSecret(id, secret string) error {
+	if a.vault == nil {
+		return fmt.Errorf("凭据库不可用")
+	}
+	for _, credential := range a.vault.List() {
+		if credential.ID == id {
+			if _, err := a.vault.Put(id, credential.Name, secret); err != nil {
+				return fmt.Errorf("保存凭据失败: %w", err)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("凭据不存在: %s", id)
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"yuque-spider-gui/internal/spider"
+)
+
+// hookSettingsFile 任务钩子设置文件名
+const hookSettingsFile = "hooks.json"
+
+// defaultHookTimeout 钩子默认超时时间(秒)
+const defaultHookTimeout = 30
+
+// maxHookResults 保留的钩子执行记录数
+const maxHookResults = 100
+
+// maxHookOutput 记录的命令输出或响应内容的最大字节数
+const maxHookOutput = 4096
+
+// HookSettings 任务结束后执行的钩子
+type HookSettings struct {
+	// WebhookURL 以 POST 发送 JSON 负载的地址, 为空时不发送
+	WebhookURL string `json:"webhookUrl"`
+	// WebhookHeaders 附加的请求头, 如鉴权信息
+	WebhookHeaders map[string]string `json:"webhookHeaders,omitempty"`
+	// Command 本地命令, 通过系统 shell 执行, JSON 负载从标准输入传入, 为空时不执行
+	Command string `json:"command"`
+	// Timeout 每个钩子的超时时间(秒)
+	Timeout int `json:"timeout"`
+	// OnCompleted, OnFailed 在任务完成或失败时触发
+	OnCompleted bool `json:"onCompleted"`
+	OnFailed    bool `json:"onFailed"`
+}
+
+// HookPayload 发送给钩子的任务结果
+type HookPayload struct {
+	Event string `json:"event"` // task.completed, task.failed
+	Task  struct {
+		ID          string     `json:"id"`
+		URL         string     `json:"url"`
+		Status      TaskStatus `json:"status"`
+		Error       string     `json:"error,omitempty"`
+		OutputPath  string     `json:"outputPath"`
+		StartedAt   *time.Time `json:"startedAt,omitempty"`
+		CompletedAt *time.Time `json:"completedAt,omitempty"`
+	} `json:"task"`
+	Book struct {
+		Title string `json:"title"`
+		Dir   string `json:"dir,omitempty"`
+	} `json:"book"`
+	Counts struct {
+		Total    int `json:"total"`
+		Finished int `json:"finished"`
+		Skipped  int `json:"skipped"`
+		Failed   int `json:"failed"`
+	} `json:"counts"`
+	Failures []spider.DocFailure `json:"failures"`
+}
+
+// HookResult 钩子执行结果
+type HookResult struct {
+	TaskID   string    `json:"taskId"`
+	Event    string    `json:"event"`
+	Kind     string    `json:"kind"` // webhook, command
+	Target   string    `json:"target"`
+	Success  bool      `json:"success"`
+	Status   int       `json:"status,omitempty"` // HTTP 状态码或命令退出码
+	Output   string    `json:"output,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Duration int64     `json:"duration"` // 毫秒
+	RunAt    time.Time `json:"runAt"`
+}
+
+// hookLog 最近的钩子执行记录
+type hookLog struct {
+	mu      sync.Mutex
+	results []HookResult
+}
+
+func (l *hookLog) add(result HookResult) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.results = append(l.results, result)
+	if len(l.results) > maxHookResults {
+		l.results = l.results[len(l.results)-maxHookResults:]
+	}
+}
+
+// GetHookSettings 获取任务钩子设置
+func (a *App) GetHookSettings() (HookSettings, error) {
+	return loadHookSettings()
+}
+
+// SaveHookSettings 保存任务钩子设置
+func (a *App) SaveHookSettings(settings HookSettings) error {
+	if settings.Timeout <= 0 {
+		settings.Timeout = defaultHookTimeout
+	}
+	dir, err := appConfigDir()
+	if err != nil {
+		return fmt.Errorf("保存钩子设置失败: %w", err)
+	}
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return fmt.Errorf("保存钩子设置失败: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, hookSettingsFile), data, 0600); err != nil {
+		return fmt.Errorf("保存钩子设置失败: %w", err)
+	}
+	return nil
+}
+
+// GetHookResults 获取最近的钩子执行记录, 最近的在前
+func (a *App) GetHookResults() []HookResult {
+	a.hooks.mu.Lock()
+	defer a.hooks.mu.Unlock()
+
+	results := make([]HookResult, 0, len(a.hooks.results))
+	for i := len(a.hooks.results) - 1; i >= 0; i-- {
+		results = append(results, a.hooks.results[i])
+	}
+	return results
+}
+
+// newHookPayload 根据任务结果生成钩子负载, 调用方需持有锁
+func newHookPayload(task *DownloadTaskItem) HookPayload {
+	var payload HookPayload
+	payload.Event = "task." + string(task.Status)
+	payload.Task.ID = task.ID
+	payload.Task.URL = task.URL
+	payload.Task.Status = task.Status
+	payload.Task.Error = task.Error
+	payload.Task.OutputPath = task.OutputPath
+	payload.Task.StartedAt = task.StartedAt
+	payload.Task.CompletedAt = task.CompletedAt
+	payload.Book.Title = task.Progress.BookTitle
+	payload.Book.Dir = task.Progress.BookDir
+	payload.Counts.Total = task.Progress.TotalDocs
+	payload.Counts.Finished = task.Progress.FinishedDocs
+	payload.Counts.Skipped = task.Progress.SkippedDocs
+	payload.Counts.Failed = len(task.Progress.Failures)
+	payload.Failures = append([]spider.DocFailure{}, task.Progress.Failures...)
+	return payload
+}
+
+// runHooks 按设置执行任务结束钩子, 结果记录到任务日志并通知前端
+func (a *App) runHooks(payload HookPayload, logger *slog.Logger) {
+	settings, err := loadHookSettings()
+	if err != nil {
+		logger.Error("读取钩子设置失败", "err", err)
+		return
+	}
+	switch payload.Task.Status {
+	case TaskStatusCompleted:
+		if !settings.OnCompleted {
+			return
+		}
+	case TaskStatusFailed:
+		if !settings.OnFailed {
+			return
+		}
+	default:
+		return
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	timeout := time.Duration(settings.Timeout) * time.Second
+
+	if settings.WebhookURL != "" {
+		a.recordHook(runWebhook(settings, body, timeout), payload, logger)
+	}
+	if settings.Command != "" {
+		a.recordHook(runHookCommand(settings.Command, payload, body, timeout), payload, logger)
+	}
+}
+
+// recordHook 记录钩子结果
+func (a *App) recordHook(result HookResult, payload HookPayload, logger *slog.Logger) {
+	result.TaskID = payload.Task.ID
+	result.Event = payload.Event
+	if result.Success {
+		logger.Info("钩子执行成功", "kind", result.Kind, "target", result.Target, "event", result.Event, "durationMs", result.Duration)
+	} else {
+		logger.Error("钩子执行失败", "kind", result.Kind, "target", result.Target, "event", result.Event, "err", result.Error)
+	}
+	a.hooks.add(result)
+	a.emit("hook:result", result)
+}
+
+// runWebhook 发送 webhook 请求, 2xx 视为成功
+func runWebhook(settings HookSettings, body []byte, timeout time.Duration) (result HookResult) {
+	result = HookResult{Kind: "webhook", Target: settings.WebhookURL, RunAt: time.Now()}
+	defer func() { result.Duration = time.Since(result.RunAt).Milliseconds() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", settings.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range settings.WebhookHeaders {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+
+	output, _ := io.ReadAll(io.LimitReader(resp.Body, maxHookOutput))
+	result.Status = resp.StatusCode
+	result.Output = string(output)
+	result.Success = resp.StatusCode >= 200 && resp.StatusCode < 300
+	if !result.Success {
+		result.Error = fmt.Sprintf("状态码: %d", resp.StatusCode)
+	}
+	return result
+}
+
+// runHookCommand 通过系统 shell 执行命令, 任务信息通过环境变量和标准输入传入
+func runHookCommand(command string, payload HookPayload, body []byte, timeout time.Duration) (result HookResult) {
+	result = HookResult{Kind: "command", Target: command, RunAt: time.Now()}
+	defer func() { result.Duration = time.Since(result.RunAt).Milliseconds() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if goruntime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Dir = payload.Task.OutputPath
+	// 超时后子进程可能仍占用输出管道, 限制等待时间
+	cmd.WaitDelay = time.Second
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"YUQUE_EVENT="+payload.Event,
+		"YUQUE_TASK_ID="+payload.Task.ID,
+		"YUQUE_TASK_STATUS="+string(payload.Task.Status),
+		"YUQUE_URL="+payload.Task.URL,
+		"YUQUE_OUTPUT_PATH="+payload.Task.OutputPath,
+		"YUQUE_BOOK_DIR="+payload.Book.Dir,
+		"YUQUE_BOOK_TITLE="+payload.Book.Title,
+		fmt.Sprintf("YUQUE_FAILED_DOCS=%d", payload.Counts.Failed),
+	)
+
+	output, err := cmd.CombinedOutput()
+	if len(output) > maxHookOutput {
+		output = output[:maxHookOutput]
+	}
+	result.Output = strings.ToValidUTF8(string(output), "")
+	if cmd.ProcessState != nil {
+		result.Status = cmd.ProcessState.ExitCode()
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		result.Error = fmt.Sprintf("执行超时(%s)", timeout)
+		return result
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Success = true
+	return result
+}
+
+// loadHookSettings 读取钩子设置, 未配置时返回默认值
+func loadHookSettings() (HookSettings, error) {
+	settings := HookSettings{Timeout: defaultHookTimeout, OnCompleted: true, OnFailed: true}
+	dir, err := appConfigDir()
+	if err != nil {
+		return settings, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, hookSettingsFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return settings, nil
+		}
+		return settings, err
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return settings, err
+	}
+	if settings.Timeout <= 0 {
+		settings.Timeout = defaultHookTimeout
+	}
+	return settings, nil
+}
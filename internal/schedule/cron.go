@@ -0,0 +1,186 @@
+// Package schedule 解析类 cron 表达式并计算下次运行时间
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule 周期调度
+type Schedule interface {
+	// Next 返回 t 之后的下一次运行时间
+	Next(t time.Time) time.Time
+}
+
+// descriptors 预定义的表达式
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// field 单个字段的取值范围
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = []field{
+	{"分钟", 0, 59},
+	{"小时", 0, 23},
+	{"日期", 1, 31},
+	{"月份", 1, 12},
+	{"星期", 0, 6},
+}
+
+// Parse 解析调度表达式, 支持:
+//   - 五段 cron 表达式(分 时 日 月 周), 每段支持 * , - / 以及星期中的 7 表示周日
+//   - @yearly @monthly @weekly @daily @hourly 等预定义表达式
+//   - @every <间隔>, 如 @every 6h, 间隔不少于 1 分钟
+func Parse(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if rest, ok := strings.CutPrefix(expr, "@every "); ok {
+		interval, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("无效的间隔: %w", err)
+		}
+		if interval < time.Minute {
+			return nil, fmt.Errorf("间隔不能少于 1 分钟")
+		}
+		return everySchedule{interval: interval}, nil
+	}
+	if spec, ok := descriptors[strings.ToLower(expr)]; ok {
+		expr = spec
+	}
+
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("表达式应包含 %d 个字段: %q", len(fields), expr)
+	}
+
+	var sched cronSchedule
+	masks := []*uint64{&sched.minute, &sched.hour, &sched.dom, &sched.month, &sched.dow}
+	for i, part := range parts {
+		f := fields[i]
+		if i == 4 {
+			// 星期允许使用 7 表示周日
+			f.max = 7
+		}
+		mask, err := parseField(part, f)
+		if err != nil {
+			return nil, err
+		}
+		*masks[i] = mask
+	}
+	if sched.dow&(1<<7) != 0 {
+		sched.dow = sched.dow&^(1<<7) | 1
+	}
+	sched.domAny = parts[2] == "*" || strings.HasPrefix(parts[2], "*/")
+	sched.dowAny = parts[4] == "*" || strings.HasPrefix(parts[4], "*/")
+	return sched, nil
+}
+
+// parseField 将单个字段解析为取值位图
+func parseField(part string, f field) (uint64, error) {
+	var mask uint64
+	for _, item := range strings.Split(part, ",") {
+		rangePart, step := item, 1
+		if before, after, ok := strings.Cut(item, "/"); ok {
+			n, err := strconv.Atoi(after)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("%s字段步长无效: %q", f.name, item)
+			}
+			rangePart, step = before, n
+		}
+
+		low, high := f.min, f.max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			before, after, _ := strings.Cut(rangePart, "-")
+			var err1, err2 error
+			low, err1 = strconv.Atoi(before)
+			high, err2 = strconv.Atoi(after)
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("%s字段范围无效: %q", f.name, item)
+			}
+		default:
+			n, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("%s字段无效: %q", f.name, item)
+			}
+			low, high = n, n
+			// 形如 5/15 表示从 5 开始每隔 15
+			if step > 1 {
+				high = f.max
+			}
+		}
+		if low < f.min || high > f.max || low > high {
+			return 0, fmt.Errorf("%s字段超出范围 %d-%d: %q", f.name, f.min, f.max, item)
+		}
+
+		for v := low; v <= high; v += step {
+			mask |= 1 << v
+		}
+	}
+	return mask, nil
+}
+
+// cronSchedule 五段 cron 表达式
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// domAny, dowAny 日期或星期为通配, 两者都指定时满足其一即可(与 cron 一致)
+	domAny, dowAny bool
+}
+
+// Next 逐级推进到满足全部字段的时间, 使用 t 所在的时区
+func (s cronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// 最多向后查找五年, 避免 2 月 30 日之类的表达式死循环
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// everySchedule 固定间隔
+type everySchedule struct {
+	interval time.Duration
+}
+
+func (s everySchedule) Next(t time.Time) time.Time {
+	return t.Add(s.interval).Truncate(time.Second)
+}
@@ -0,0 +1,510 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"yuque-spider-gui/internal/spider"
+)
+
+// indexVersion 索引文件格式版本, 版本不一致时丢弃旧索引
+const indexVersion = 1
+
+// BM25 参数
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+	// titleWeight 标题中的词按出现多次计算
+	titleWeight = 3
+)
+
+// 摘要长度(字符数)
+const (
+	snippetBefore = 30
+	snippetAfter  = 90
+)
+
+// Document 已索引的文档
+type Document struct {
+	ID        int    `json:"id"`
+	BookDir   string `json:"bookDir"`
+	BookTitle string `json:"bookTitle"`
+	Title     string `json:"title"`
+	// Path 相对知识库目录的文件路径, 以 / 分隔
+	Path      string `json:"path"`
+	SHA256    string `json:"sha256"`
+	UpdatedAt string `json:"updatedAt,omitempty"`
+	// Terms 词频, 加载时据此重建倒排索引
+	Terms  map[string]int `json:"terms"`
+	Length int            `json:"length"`
+}
+
+// Hit 搜索结果
+type Hit struct {
+	BookDir   string  `json:"bookDir"`
+	BookTitle string  `json:"bookTitle"`
+	Title     string  `json:"title"`
+	Path      string  `json:"path"`
+	LocalPath string  `json:"localPath"`
+	UpdatedAt string  `json:"updatedAt,omitempty"`
+	Score     float64 `json:"score"`
+	Snippet   string  `json:"snippet"`
+}
+
+// Book 已索引的知识库
+type Book struct {
+	Dir   string `json:"dir"`
+	Title string `json:"title"`
+	Docs  int    `json:"docs"`
+}
+
+// UpdateStats 更新索引的结果
+type UpdateStats struct {
+	Added     int `json:"added"`
+	Updated   int `json:"updated"`
+	Removed   int `json:"removed"`
+	Unchanged int `json:"unchanged"`
+}
+
+// indexFile 索引文件内容
+type indexFile struct {
+	Version   int         `json:"version"`
+	NextID    int         `json:"nextId"`
+	UpdatedAt time.Time   `json:"updatedAt"`
+	Docs      []*Document `json:"docs"`
+}
+
+// Index 本地全文索引, 覆盖所有已下载的知识库
+type Index struct {
+	mu       sync.RWMutex
+	path     string
+	nextID   int
+	docs     map[int]*Document
+	byPath   map[string]int
+	postings map[string]map[int]int
+	// totalLength 全部文档长度之和, 用于计算平均长度
+	totalLength int
+}
+
+// New 创建保存到 path 的空索引
+func New(path string) *Index {
+	return &Index{
+		path:     path,
+		nextID:   1,
+		docs:     make(map[int]*Document),
+		byPath:   make(map[string]int),
+		postings: make(map[string]map[int]int),
+	}
+}
+
+// Open 打开索引文件, 文件不存在或版本不一致时创建空索引
+func Open(path string) (*Index, error) {
+	idx := New(path)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return idx, nil
+		}
+		return nil, err
+	}
+	var file indexFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("解析搜索索引失败: %w", err)
+	}
+	if file.Version != indexVersion {
+		return idx, nil
+	}
+
+	idx.nextID = file.NextID
+	for _, doc := range file.Docs {
+		idx.add(doc)
+	}
+	return idx, nil
+}
+
+// docKey 文档的唯一标识
+func docKey(bookDir, path string) string {
+	return bookDir + "\x00" + path
+}
+
+// add 加入文档并更新倒排索引, 调用方需持有锁
+func (idx *Index) add(doc *Document) {
+	idx.docs[doc.ID] = doc
+	idx.byPath[docKey(doc.BookDir, doc.Path)] = doc.ID
+	for term, tf := range doc.Terms {
+		postings := idx.postings[term]
+		if postings == nil {
+			postings = make(map[int]int)
+			idx.postings[term] = postings
+		}
+		postings[doc.ID] = tf
+	}
+	idx.totalLength += doc.Length
+	if doc.ID >= idx.nextID {
+		idx.nextID = doc.ID + 1
+	}
+}
+
+// remove 移除文档, 调用方需持有锁
+func (idx *Index) remove(id int) {
+	doc, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+	for term := range doc.Terms {
+		delete(idx.postings[term], id)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	idx.totalLength -= doc.Length
+	delete(idx.byPath, docKey(doc.BookDir, doc.Path))
+	delete(idx.docs, id)
+}
+
+// UpdateBook 按知识库的下载清单增量更新索引: 校验和未变的文档跳过, 已删除的文档移出索引
+func (idx *Index) UpdateBook(bookDir string) (*UpdateStats, error) {
+	bookDir, err := filepath.Abs(bookDir)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := spider.ReadManifest(bookDir)
+	if err != nil {
+		return nil, fmt.Errorf("读取下载清单失败: %w", err)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	stats := &UpdateStats{}
+	seen := make(map[int]bool)
+	metaChanged := false
+	for _, entry := range manifest.Entries {
+		if !indexable(entry.Path) {
+			continue
+		}
+
+		id, exists := idx.byPath[docKey(bookDir, entry.Path)]
+		// 标题参与索引, 内容与标题都未变时才跳过
+		if exists && entry.SHA256 != "" && idx.docs[id].SHA256 == entry.SHA256 && idx.docs[id].Title == entry.Title {
+			doc := idx.docs[id]
+			if doc.BookTitle != manifest.Book.Title || doc.UpdatedAt != entry.UpdatedAt {
+				doc.BookTitle = manifest.Book.Title
+				doc.UpdatedAt = entry.UpdatedAt
+				metaChanged = true
+			}
+			seen[id] = true
+			stats.Unchanged++
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(bookDir, filepath.FromSlash(entry.Path)))
+		if err != nil {
+			// 文件被手动删除时按已删除处理
+			continue
+		}
+		if exists {
+			idx.remove(id)
+			stats.Updated++
+		} else {
+			id = idx.nextID
+			stats.Added++
+		}
+
+		doc := &Document{
+			ID:        id,
+			BookDir:   bookDir,
+			BookTitle: manifest.Book.Title,
+			Title:     entry.Title,
+			Path:      entry.Path,
+			SHA256:    entry.SHA256,
+			UpdatedAt: entry.UpdatedAt,
+			Terms:     make(map[string]int),
+		}
+		for _, term := range Tokenize(entry.Title) {
+			doc.Terms[term] += titleWeight
+			doc.Length += titleWeight
+		}
+		for _, term := range Tokenize(plainText(string(content))) {
+			doc.Terms[term]++
+			doc.Length++
+		}
+		idx.add(doc)
+		seen[id] = true
+	}
+
+	for id, doc := range idx.docs {
+		if doc.BookDir == bookDir && !seen[id] {
+			idx.remove(id)
+			stats.Removed++
+		}
+	}
+
+	if metaChanged || stats.Added+stats.Updated+stats.Removed > 0 {
+		if err := idx.save(); err != nil {
+			return nil, fmt.Errorf("保存搜索索引失败: %w", err)
+		}
+	}
+	return stats, nil
+}
+
+// indexable 是否为可索引的文本文件
+func indexable(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".csv":
+		return true
+	}
+	return false
+}
+
+// RemoveBook 从索引中移除知识库
+func (idx *Index) RemoveBook(bookDir string) error {
+	bookDir, err := filepath.Abs(bookDir)
+	if err != nil {
+		return err
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	removed := false
+	for id, doc := range idx.docs {
+		if doc.BookDir == bookDir {
+			idx.remove(id)
+			removed = true
+		}
+	}
+	if !removed {
+		return nil
+	}
+	return idx.save()
+}
+
+// Books 列出已索引的知识库
+func (idx *Index) Books() []Book {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	byDir := make(map[string]*Book)
+	for _, doc := range idx.docs {
+		book := byDir[doc.BookDir]
+		if book == nil {
+			book = &Book{Dir: doc.BookDir, Title: doc.BookTitle}
+			byDir[doc.BookDir] = book
+		}
+		book.Docs++
+	}
+
+	books := make([]Book, 0, len(byDir))
+	for _, book := range byDir {
+		books = append(books, *book)
+	}
+	sort.Slice(books, func(i, j int) bool { return books[i].Title < books[j].Title })
+	return books
+}
+
+// Search 搜索文档, 返回按 BM25 得分排序的前 limit 个结果. 文档需包含全部查询词,
+// 单个汉字会匹配所有包含该字的词
+func (idx *Index) Search(query string, limit int) []Hit {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	groups := idx.queryGroups(query)
+	if len(groups) == 0 || len(idx.docs) == 0 {
+		return []Hit{}
+	}
+
+	// 计算每组的 BM25 得分, 文档需命中每一组
+	n := float64(len(idx.docs))
+	avgLength := float64(idx.totalLength) / n
+	var scores map[int]float64
+	for _, group := range groups {
+		groupScores := make(map[int]float64)
+		for _, term := range group {
+			postings := idx.postings[term]
+			df := float64(len(postings))
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			for id, tf := range postings {
+				if scores != nil {
+					if _, ok := scores[id]; !ok {
+						continue
+					}
+				}
+				length := float64(idx.docs[id].Length)
+				f := float64(tf)
+				groupScores[id] += idf * f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*length/avgLength))
+			}
+		}
+		if scores != nil {
+			for id, score := range groupScores {
+				groupScores[id] = score + scores[id]
+			}
+		}
+		scores = groupScores
+		if len(scores) == 0 {
+			return []Hit{}
+		}
+	}
+
+	ids := make([]int, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	if limit > 0 && len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	needles := queryNeedles(query)
+	hits := make([]Hit, 0, len(ids))
+	for _, id := range ids {
+		doc := idx.docs[id]
+		localPath := filepath.Join(doc.BookDir, filepath.FromSlash(doc.Path))
+		hits = append(hits, Hit{
+			BookDir:   doc.BookDir,
+			BookTitle: doc.BookTitle,
+			Title:     doc.Title,
+			Path:      doc.Path,
+			LocalPath: localPath,
+			UpdatedAt: doc.UpdatedAt,
+			Score:     scores[id],
+			Snippet:   snippet(localPath, needles),
+		})
+	}
+	return hits
+}
+
+// queryGroups 将查询拆分为词组, 每组内的词任一命中即可, 调用方需持有锁
+func (idx *Index) queryGroups(query string) [][]string {
+	var groups [][]string
+	seen := make(map[string]bool)
+	for _, term := range Tokenize(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		// 索引中的汉字按两字切分, 单字查询需扩展为包含该字的词
+		if r, size := utf8.DecodeRuneInString(term); size == len(term) && isCJK(r) {
+			group := []string{}
+			for candidate := range idx.postings {
+				if strings.ContainsRune(candidate, r) {
+					group = append(group, candidate)
+				}
+			}
+			sort.Strings(group)
+			groups = append(groups, group)
+			continue
+		}
+		groups = append(groups, []string{term})
+	}
+	return groups
+}
+
+// queryNeedles 生成摘要时查找的关键词: 先查找查询中连续的文字片段, 找不到时再查找分词结果
+func queryNeedles(query string) [][]rune {
+	var needles [][]rune
+	for _, field := range strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		needles = append(needles, []rune(field))
+	}
+	for _, term := range Tokenize(query) {
+		needles = append(needles, []rune(term))
+	}
+	return needles
+}
+
+// snippet 读取文档并截取关键词附近的文字
+func snippet(path string, needles [][]rune) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	text := []rune(strings.Join(strings.Fields(plainText(string(data))), " "))
+	lower := make([]rune, len(text))
+	for i, r := range text {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	pos := 0
+	for _, needle := range needles {
+		if i := indexRunes(lower, needle); i >= 0 {
+			pos = i
+			break
+		}
+	}
+
+	start := max(pos-snippetBefore, 0)
+	end := min(pos+snippetAfter, len(text))
+	result := string(text[start:end])
+	if start > 0 {
+		result = "…" + result
+	}
+	if end < len(text) {
+		result += "…"
+	}
+	return result
+}
+
+// indexRunes 查找子串位置(按字符计)
+func indexRunes(text, needle []rune) int {
+	if len(needle) == 0 {
+		return -1
+	}
+	for i := 0; i+len(needle) <= len(text); i++ {
+		match := true
+		for j, r := range needle {
+			if text[i+j] != r {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
+
+// save 写入索引文件, 调用方需持有锁
+func (idx *Index) save() error {
+	file := indexFile{
+		Version:   indexVersion,
+		NextID:    idx.nextID,
+		UpdatedAt: time.Now(),
+		Docs:      make([]*Document, 0, len(idx.docs)),
+	}
+	for _, doc := range idx.docs {
+		file.Docs = append(file.Docs, doc)
+	}
+	sort.Slice(file.Docs, func(i, j int) bool { return file.Docs[i].ID < file.Docs[j].ID })
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(idx.path), 0700); err != nil {
+		return err
+	}
+	tmp := idx.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, idx.path)
+}
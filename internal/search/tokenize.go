@@ -0,0 +1,70 @@
+package search
+
+import (
+	"regexp"
+	"unicode"
+)
+
+// maxTokenLength 过长的词(如 base64 数据)不建立索引
+const maxTokenLength = 64
+
+var (
+	// markdownImageRegex 图片只保留替代文本
+	markdownImageRegex = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	// markdownLinkRegex 链接只保留文字
+	markdownLinkRegex = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	// htmlTagRegex 内嵌的 HTML 标签
+	htmlTagRegex = regexp.MustCompile(`<[^>]+>`)
+)
+
+// plainText 去掉 Markdown 中不需要检索的链接地址与 HTML 标签
+func plainText(markdown string) string {
+	text := markdownImageRegex.ReplaceAllString(markdown, "$1")
+	text = markdownLinkRegex.ReplaceAllString(text, "$1")
+	return htmlTagRegex.ReplaceAllString(text, " ")
+}
+
+// isCJK 是否为中日韩文字, 这些文字按相邻两字切分
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.In(r, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// Tokenize 分词: 连续的字母数字为一个词, 中日韩文字切分为相邻两字(单字时保留单字), 统一转为小写
+func Tokenize(text string) []string {
+	var tokens []string
+	var word, cjk []rune
+
+	flushWord := func() {
+		if len(word) > 0 && len(word) <= maxTokenLength {
+			tokens = append(tokens, string(word))
+		}
+		word = word[:0]
+	}
+	flushCJK := func() {
+		if len(cjk) == 1 {
+			tokens = append(tokens, string(cjk))
+		}
+		for i := 0; i+1 < len(cjk); i++ {
+			tokens = append(tokens, string(cjk[i:i+2]))
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range text {
+		r = unicode.ToLower(r)
+		switch {
+		case isCJK(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return tokens
+}
@@ -0,0 +1,342 @@
+package spider
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ChangeReportFileName 变更报告文件名
+const ChangeReportFileName = "CHANGES.md"
+
+// ChangeKind 文档变更类型
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"
+	ChangeRemoved  ChangeKind = "removed"
+	ChangeRenamed  ChangeKind = "renamed"
+	ChangeModified ChangeKind = "modified"
+)
+
+// diffableExts 生成行级差异的文件类型
+var diffableExts = map[string]bool{".md": true, ".csv": true}
+
+// DocChange 单个文档的变更
+type DocChange struct {
+	Kind  ChangeKind `json:"kind"`
+	UUID  string     `json:"uuid"`
+	Title string     `json:"title"`
+	// Path 本次路径, 删除的文档为空
+	Path string `json:"path,omitempty"`
+	// OldPath 上次路径, 新增的文档为空
+	OldPath string `json:"oldPath,omitempty"`
+	// Modified 重命名的文档内容是否也有变化
+	Modified bool     `json:"modified,omitempty"`
+	Stat     DiffStat `json:"stat"`
+	// Diff 统一格式的行级差异, 非文本文件为空
+	Diff string `json:"diff,omitempty"`
+}
+
+// ChangeReport 同一知识库两次同步之间的变更报告
+type ChangeReport struct {
+	BookTitle   string      `json:"bookTitle"`
+	PreviousAt  time.Time   `json:"previousAt"`
+	GeneratedAt time.Time   `json:"generatedAt"`
+	Changes     []DocChange `json:"changes"`
+}
+
+// Count 统计指定类型的变更数量
+func (r *ChangeReport) Count(kind ChangeKind) int {
+	count := 0
+	for _, change := range r.Changes {
+		if change.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+// snapshot 上次同步的清单与文本内容, 需在文件被覆盖前读取
+type snapshot struct {
+	manifest *Manifest
+	contents map[string]string
+}
+
+// loadSnapshot 读取知识库目录中上次同步的结果, 首次同步时返回 nil
+func loadSnapshot(bookDir string) *snapshot {
+	manifest, err := ReadManifest(bookDir)
+	if err != nil {
+		return nil
+	}
+
+	snap := &snapshot{manifest: manifest, contents: make(map[string]string)}
+	for _, entry := range manifest.Entries {
+		if entry.Path == "" || !diffableExts[path.Ext(entry.Path)] {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(bookDir, filepath.FromSlash(entry.Path)))
+		if err != nil {
+			continue
+		}
+		snap.contents[entry.Path] = string(data)
+	}
+	return snap
+}
+
+// compareSnapshot 按节点 UUID 比较上次与本次的清单, 生成变更报告
+func compareSnapshot(bookDir string, prev *snapshot, current *Manifest) *ChangeReport {
+	report := &ChangeReport{
+		BookTitle:   current.Book.Title,
+		PreviousAt:  prev.manifest.GeneratedAt,
+		GeneratedAt: current.GeneratedAt,
+		Changes:     []DocChange{},
+	}
+
+	// 本次下载失败的文档仍在目录中, 保留上次的文件, 不算删除
+	present := make(map[string]bool)
+	for _, entry := range current.Entries {
+		present[entry.UUID] = true
+	}
+
+	old := make(map[string]ManifestEntry)
+	for _, entry := range prev.manifest.Entries {
+		if entry.Path != "" {
+			old[entry.UUID] = entry
+		}
+	}
+
+	for _, entry := range current.Entries {
+		if entry.Path == "" {
+			delete(old, entry.UUID)
+			continue
+		}
+		before, existed := old[entry.UUID]
+		delete(old, entry.UUID)
+
+		change := DocChange{UUID: entry.UUID, Title: entry.Title, Path: entry.Path}
+		if !existed {
+			change.Kind = ChangeAdded
+			report.Changes = append(report.Changes, change)
+			continue
+		}
+
+		change.OldPath = before.Path
+		modified := before.SHA256 != entry.SHA256
+		if modified {
+			change.Diff, change.Stat = snapshotDiff(bookDir, prev, before.Path, entry.Path)
+		}
+		switch {
+		case before.Path != entry.Path:
+			change.Kind = ChangeRenamed
+			change.Modified = modified
+		case modified:
+			change.Kind = ChangeModified
+		default:
+			continue
+		}
+		report.Changes = append(report.Changes, change)
+	}
+
+	// 剩余的旧节点在本次同步中已不存在, 按原目录顺序输出
+	for _, entry := range prev.manifest.Entries {
+		if _, ok := old[entry.UUID]; ok && !present[entry.UUID] {
+			report.Changes = append(report.Changes, DocChange{
+				Kind:    ChangeRemoved,
+				UUID:    entry.UUID,
+				Title:   entry.Title,
+				OldPath: entry.Path,
+			})
+		}
+	}
+
+	return report
+}
+
+// pruneStaleFiles 删除上次同步留下、本次已不再使用的文件: 已删除或重命名文档的正文、评论、历史版本和不再被引用的资源.
+// 本次下载失败的文档保留上次的文件. 返回已删除的路径
+func pruneStaleFiles(bookDir string, prev, current *Manifest) ([]string, error) {
+	keep := make(map[string]bool)
+	for _, entry := range current.Entries {
+		for _, p := range entryFiles(entry) {
+			keep[p] = true
+		}
+	}
+	for _, asset := range current.Assets {
+		keep[asset.Path] = true
+	}
+
+	// 下载失败的文档沿用上次的文件
+	failed := make(map[string]bool)
+	for _, entry := range current.Entries {
+		if entry.Path == "" && entry.SourceURL != "" {
+			failed[entry.UUID] = true
+		}
+	}
+	for _, entry := range prev.Entries {
+		if failed[entry.UUID] {
+			for _, p := range entryFiles(entry) {
+				keep[p] = true
+			}
+			for _, p := range entry.Assets {
+				keep[p] = true
+			}
+		}
+	}
+
+	var stale []string
+	for _, entry := range prev.Entries {
+		stale = append(stale, entryFiles(entry)...)
+		stale = append(stale, entry.Assets...)
+	}
+	for _, asset := range prev.Assets {
+		stale = append(stale, asset.Path)
+	}
+
+	var removed []string
+	seen := make(map[string]bool)
+	for _, p := range stale {
+		if p == "" || keep[p] || seen[p] || !filepath.IsLocal(filepath.FromSlash(p)) {
+			continue
+		}
+		seen[p] = true
+		full := filepath.Join(bookDir, filepath.FromSlash(p))
+		if _, err := os.Lstat(full); err != nil {
+			continue
+		}
+		if err := os.RemoveAll(full); err != nil {
+			return removed, fmt.Errorf("删除过期文件失败: %w", err)
+		}
+		removed = append(removed, p)
+		removeEmptyParents(bookDir, filepath.Dir(full))
+	}
+	return removed, nil
+}
+
+// entryFiles 清单节点对应的本地文件
+func entryFiles(entry ManifestEntry) []string {
+	var files []string
+	for _, p := range []string{entry.Path, entry.Comments, entry.History} {
+		if p != "" {
+			files = append(files, p)
+		}
+	}
+	return files
+}
+
+// removeEmptyParents 自下而上删除空目录, 不超出知识库目录
+func removeEmptyParents(bookDir, dir string) {
+	for dir != bookDir && strings.HasPrefix(dir, bookDir+string(filepath.Separator)) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// snapshotDiff 比较旧快照中的内容与磁盘上的新文件
+func snapshotDiff(bookDir string, prev *snapshot, oldPath, newPath string) (string, DiffStat) {
+	oldText, ok := prev.contents[oldPath]
+	if !ok || !diffableExts[path.Ext(newPath)] {
+		return "", DiffStat{}
+	}
+	data, err := os.ReadFile(filepath.Join(bookDir, filepath.FromSlash(newPath)))
+	if err != nil {
+		return "", DiffStat{}
+	}
+	return UnifiedDiff(oldText, string(data), "a/"+oldPath, "b/"+newPath)
+}
+
+// Markdown 将变更报告渲染为 Markdown
+func (r *ChangeReport) Markdown() string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("# %s 变更报告\n\n", r.BookTitle))
+	b.WriteString(fmt.Sprintf("- 上次同步: %s\n", r.PreviousAt.Local().Format("2006-01-02 15:04:05")))
+	b.WriteString(fmt.Sprintf("- 本次同步: %s\n", r.GeneratedAt.Local().Format("2006-01-02 15:04:05")))
+	b.WriteString(fmt.Sprintf("- 新增 %d, 删除 %d, 重命名 %d, 修改 %d\n",
+		r.Count(ChangeAdded), r.Count(ChangeRemoved), r.Count(ChangeRenamed), r.Count(ChangeModified)))
+
+	if len(r.Changes) == 0 {
+		b.WriteString("\n没有变更。\n")
+		return b.String()
+	}
+
+	sections := []struct {
+		kind  ChangeKind
+		title string
+	}{
+		{ChangeAdded, "新增"},
+		{ChangeRemoved, "删除"},
+		{ChangeRenamed, "重命名"},
+		{ChangeModified, "修改"},
+	}
+	for _, section := range sections {
+		if r.Count(section.kind) == 0 {
+			continue
+		}
+		b.WriteString(fmt.Sprintf("\n## %s\n\n", section.title))
+		for _, change := range r.Changes {
+			if change.Kind != section.kind {
+				continue
+			}
+			switch change.Kind {
+			case ChangeAdded:
+				b.WriteString(fmt.Sprintf("- [%s](%s)\n", escapeLinkText(change.Title), escapeURLPath(change.Path)))
+			case ChangeRemoved:
+				b.WriteString(fmt.Sprintf("- %s (`%s`)\n", change.Title, change.OldPath))
+			case ChangeRenamed:
+				b.WriteString(fmt.Sprintf("- [%s](%s): `%s` → `%s`", escapeLinkText(change.Title), escapeURLPath(change.Path), change.OldPath, change.Path))
+				if change.Modified {
+					b.WriteString(fmt.Sprintf(" (+%d -%d)", change.Stat.Added, change.Stat.Removed))
+				}
+				b.WriteString("\n")
+			case ChangeModified:
+				b.WriteString(fmt.Sprintf("- [%s](%s) (+%d -%d)\n", escapeLinkText(change.Title), escapeURLPath(change.Path), change.Stat.Added, change.Stat.Removed))
+			}
+		}
+	}
+
+	// 行级差异
+	var diffs []DocChange
+	for _, change := range r.Changes {
+		if change.Diff != "" {
+			diffs = append(diffs, change)
+		}
+	}
+	if len(diffs) > 0 {
+		b.WriteString("\n## 差异\n")
+		for _, change := range diffs {
+			fence := codeFence(change.Diff)
+			b.WriteString(fmt.Sprintf("\n### %s\n\n%sdiff\n%s%s\n", change.Title, fence, change.Diff, fence))
+		}
+	}
+
+	return b.String()
+}
+
+// codeFence 返回比内容中最长反引号序列更长的代码块围栏
+func codeFence(content string) string {
+	longest, run := 0, 0
+	for _, r := range content {
+		if r == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	if longest < 3 {
+		return "```"
+	}
+	return strings.Repeat("`", longest+1)
+}
+
+// writeChangeReport 将变更报告写入知识库目录
+func writeChangeReport(bookDir string, report *ChangeReport) error {
+	return os.WriteFile(filepath.Join(bookDir, ChangeReportFileName), []byte(report.Markdown()), 0644)
+}
@@ -0,0 +1,166 @@
+package spider
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// 评论保存方式
+const (
+	CommentsOff      = ""
+	CommentsMarkdown = "markdown"
+	CommentsJSON     = "json"
+	CommentsAppend   = "append"
+)
+
+// Comment 文档评论
+type Comment struct {
+	ID        int    `json:"id"`
+	ParentID  int    `json:"parent_id"`
+	Body      string `json:"body"`
+	BodyHTML  string `json:"body_html"`
+	CreatedAt string `json:"created_at"`
+	User      struct {
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	} `json:"user"`
+	// Selection 划词评论所引用的原文
+	Selection string `json:"selection"`
+	// Replies 回复, 由 ParentID 组装
+	Replies []*Comment `json:"replies,omitempty"`
+}
+
+// Author 评论作者的显示名
+func (c *Comment) Author() string {
+	if c.User.Name != "" {
+		return c.User.Name
+	}
+	if c.User.Login != "" {
+		return c.User.Login
+	}
+	return "匿名用户"
+}
+
+// Text 评论的纯文本内容
+func (c *Comment) Text() string {
+	source := c.BodyHTML
+	if source == "" {
+		source = c.Body
+	}
+	if !strings.Contains(source, "<") {
+		return strings.TrimSpace(source)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(source))
+	if err != nil {
+		return strings.TrimSpace(source)
+	}
+	var paragraphs []string
+	doc.Find("p, li, pre, blockquote").Each(func(_ int, s *goquery.Selection) {
+		if text := strings.TrimSpace(s.Text()); text != "" {
+			paragraphs = append(paragraphs, text)
+		}
+	})
+	if len(paragraphs) == 0 {
+		return strings.TrimSpace(doc.Text())
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// nestComments 按 ParentID 将评论组装为回复树, 同级按时间排序
+func nestComments(comments []Comment) []*Comment {
+	byID := make(map[int]*Comment, len(comments))
+	for i := range comments {
+		comments[i].Replies = nil
+		byID[comments[i].ID] = &comments[i]
+	}
+
+	var roots []*Comment
+	for i := range comments {
+		c := &comments[i]
+		if parent, ok := byID[c.ParentID]; ok && c.ParentID != c.ID {
+			parent.Replies = append(parent.Replies, c)
+		} else {
+			roots = append(roots, c)
+		}
+	}
+
+	var sortThread func(list []*Comment)
+	sortThread = func(list []*Comment) {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].CreatedAt < list[j].CreatedAt })
+		for _, c := range list {
+			sortThread(c.Replies)
+		}
+	}
+	sortThread(roots)
+	return roots
+}
+
+// renderCommentsMarkdown 将评论树渲染为 Markdown
+func renderCommentsMarkdown(title string, threads []*Comment) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("## 评论: %s\n\n", title))
+
+	var render func(c *Comment, depth int)
+	render = func(c *Comment, depth int) {
+		prefix := strings.Repeat("> ", depth)
+		b.WriteString(fmt.Sprintf("%s**%s** · %s\n%s\n", prefix, c.Author(), c.CreatedAt, strings.TrimSpace(prefix)))
+		if c.Selection != "" {
+			b.WriteString(fmt.Sprintf("%s> 引用: %s\n%s\n", prefix, strings.Join(strings.Fields(c.Selection), " "), strings.TrimSpace(prefix)))
+		}
+		for _, line := range strings.Split(c.Text(), "\n") {
+			b.WriteString(strings.TrimRight(prefix+line, " ") + "\n")
+		}
+		b.WriteString("\n")
+		for _, reply := range c.Replies {
+			render(reply, depth+1)
+		}
+	}
+
+	for _, thread := range threads {
+		render(thread, 0)
+		b.WriteString("---\n\n")
+	}
+	return b.String()
+}
+
+// saveComments 按配置保存评论, 返回旁路文件路径(追加模式返回空)
+func (d *Downloader) saveComments(docID int, title, dirPath, baseName string, markdown *string) (string, error) {
+	comments, err := d.fetcher.FetchComments(docID)
+	if err != nil {
+		return "", err
+	}
+	if len(comments) == 0 {
+		return "", nil
+	}
+	threads := nestComments(comments)
+
+	mode := d.config.Comments
+	if mode == CommentsAppend && markdown == nil {
+		// 非 Markdown 文档无法追加, 改为旁路文件
+		mode = CommentsMarkdown
+	}
+
+	switch mode {
+	case CommentsAppend:
+		*markdown = strings.TrimRight(*markdown, "\n") + "\n\n---\n\n" + renderCommentsMarkdown(title, threads)
+		return "", nil
+	case CommentsJSON:
+		data, err := json.MarshalIndent(threads, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		fileName := baseName + ".comments.json"
+		return fileName, os.WriteFile(filepath.Join(dirPath, fileName), data, 0644)
+	default:
+		fileName := baseName + ".comments.md"
+		content := renderCommentsMarkdown(title, threads)
+		return fileName, os.WriteFile(filepath.Join(dirPath, fileName), []byte(content), 0644)
+	}
+}
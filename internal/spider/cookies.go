@@ -0,0 +1,256 @@
+package spider
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// yuqueCookieDomain 语雀 Cookie 的域名
+const yuqueCookieDomain = "yuque.com"
+
+// storedCookie 凭据中保存的 Cookie
+type storedCookie struct {
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Expires  time.Time `json:"expires,omitempty"`
+	Secure   bool      `json:"secure,omitempty"`
+	HttpOnly bool      `json:"httpOnly,omitempty"`
+}
+
+// isYuqueDomain 域名是否属于语雀
+func isYuqueDomain(domain string) bool {
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	return domain == yuqueCookieDomain || strings.HasSuffix(domain, "."+yuqueCookieDomain)
+}
+
+// ParseNetscapeCookies 解析浏览器扩展导出的 Netscape 格式 cookies.txt, 只保留语雀域名下未过期的 Cookie
+func ParseNetscapeCookies(data []byte) ([]*http.Cookie, error) {
+	var cookies []*http.Cookie
+	now := time.Now()
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := false
+		if rest, ok := strings.CutPrefix(line, "#HttpOnly_"); ok {
+			line, httpOnly = rest, true
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) < 7 {
+			return nil, fmt.Errorf("cookies.txt 格式错误: %q", line)
+		}
+		domain := fields[0]
+		if !isYuqueDomain(domain) {
+			continue
+		}
+
+		cookie := &http.Cookie{
+			Name:     fields[5],
+			Value:    strings.Join(fields[6:], "\t"),
+			Domain:   domain,
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly: httpOnly,
+		}
+		// 主机 Cookie(第二列为 FALSE)不带前导点
+		if !strings.EqualFold(fields[1], "TRUE") {
+			cookie.Domain = strings.TrimPrefix(domain, ".")
+		}
+		if expires, err := strconv.ParseInt(fields[4], 10, 64); err == nil && expires > 0 {
+			cookie.Expires = time.Unix(expires, 0)
+			if cookie.Expires.Before(now) {
+				continue
+			}
+		}
+		cookies = append(cookies, cookie)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(cookies) == 0 {
+		return nil, fmt.Errorf("没有找到语雀的 Cookie")
+	}
+	return cookies, nil
+}
+
+// ParseHARCookies 从浏览器开发者工具导出的 HAR 文件中提取发往语雀的请求 Cookie, 同名 Cookie 以最后一次请求为准
+func ParseHARCookies(data []byte) ([]*http.Cookie, error) {
+	var har struct {
+		Log struct {
+			Entries []struct {
+				Request struct {
+					URL     string `json:"url"`
+					Cookies []struct {
+						Name     string `json:"name"`
+						Value    string `json:"value"`
+						Domain   string `json:"domain"`
+						Path     string `json:"path"`
+						Expires  string `json:"expires"`
+						Secure   bool   `json:"secure"`
+						HTTPOnly bool   `json:"httpOnly"`
+					} `json:"cookies"`
+					Headers []struct {
+						Name  string `json:"name"`
+						Value string `json:"value"`
+					} `json:"headers"`
+				} `json:"request"`
+			} `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("解析 HAR 文件失败: %w", err)
+	}
+
+	byName := make(map[string]*http.Cookie)
+	var order []string
+	add := func(cookie *http.Cookie) {
+		if _, ok := byName[cookie.Name]; !ok {
+			order = append(order, cookie.Name)
+		}
+		byName[cookie.Name] = cookie
+	}
+
+	now := time.Now()
+	for _, entry := range har.Log.Entries {
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil || !isYuqueDomain(u.Hostname()) {
+			continue
+		}
+
+		if len(entry.Request.Cookies) > 0 {
+			for _, c := range entry.Request.Cookies {
+				cookie := &http.Cookie{
+					Name:     c.Name,
+					Value:    c.Value,
+					Domain:   c.Domain,
+					Path:     c.Path,
+					Secure:   c.Secure,
+					HttpOnly: c.HTTPOnly,
+				}
+				if cookie.Domain == "" || !isYuqueDomain(cookie.Domain) {
+					cookie.Domain = "." + yuqueCookieDomain
+				}
+				if expires, err := time.Parse(time.RFC3339, c.Expires); err == nil {
+					if expires.Before(now) {
+						continue
+					}
+					cookie.Expires = expires
+				}
+				add(cookie)
+			}
+			continue
+		}
+
+		// 部分浏览器只在请求头中记录 Cookie
+		for _, header := range entry.Request.Headers {
+			if !strings.EqualFold(header.Name, "Cookie") {
+				continue
+			}
+			for _, cookie := range parseCookieHeader(header.Value) {
+				add(cookie)
+			}
+		}
+	}
+
+	if len(order) == 0 {
+		return nil, fmt.Errorf("没有找到发往语雀的请求 Cookie")
+	}
+	cookies := make([]*http.Cookie, 0, len(order))
+	for _, name := range order {
+		cookies = append(cookies, byName[name])
+	}
+	return cookies, nil
+}
+
+// EncodeCookies 将 Cookie 序列化为可保存到凭据库的字符串
+func EncodeCookies(cookies []*http.Cookie) (string, error) {
+	stored := make([]storedCookie, 0, len(cookies))
+	for _, c := range cookies {
+		stored = append(stored, storedCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Expires:  c.Expires,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+		})
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// parseCookies 解析凭据字符串: EncodeCookies 生成的 JSON, 或从浏览器复制的 Cookie 请求头
+func parseCookies(credential string) []*http.Cookie {
+	credential = strings.TrimSpace(credential)
+	if strings.HasPrefix(credential, "[") {
+		var stored []storedCookie
+		if err := json.Unmarshal([]byte(credential), &stored); err == nil {
+			cookies := make([]*http.Cookie, 0, len(stored))
+			for _, c := range stored {
+				cookies = append(cookies, &http.Cookie{
+					Name:     c.Name,
+					Value:    c.Value,
+					Domain:   c.Domain,
+					Path:     c.Path,
+					Expires:  c.Expires,
+					Secure:   c.Secure,
+					HttpOnly: c.HttpOnly,
+				})
+			}
+			return cookies
+		}
+	}
+	return parseCookieHeader(credential)
+}
+
+// parseCookieHeader 解析 "name=value; name2=value2" 形式的 Cookie 请求头
+func parseCookieHeader(header string) []*http.Cookie {
+	header = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(header), "Cookie:"))
+	if header == "" {
+		return nil
+	}
+	req := http.Request{Header: http.Header{"Cookie": {header}}}
+	return req.Cookies()
+}
+
+// newCookieJar 根据凭据创建 Cookie jar. 未指定域名的 Cookie 按 API 站点归属,
+// 语雀站点的 Cookie 作用于全部 *.yuque.com 子域名, 以覆盖企业空间
+func newCookieJar(credential, baseURL string) *cookiejar.Jar {
+	jar, _ := cookiejar.New(nil)
+
+	defaultDomain := yuqueCookieDomain
+	if u, err := url.Parse(baseURL); err == nil && u.Hostname() != "" && !isYuqueDomain(u.Hostname()) {
+		defaultDomain = u.Hostname()
+	}
+
+	for _, cookie := range parseCookies(credential) {
+		if cookie.Domain == "" {
+			cookie.Domain = defaultDomain
+		}
+		if cookie.Path == "" {
+			cookie.Path = "/"
+		}
+		host := strings.TrimPrefix(cookie.Domain, ".")
+		jar.SetCookies(&url.URL{Scheme: "https", Host: host, Path: "/"}, []*http.Cookie{cookie})
+	}
+	return jar
+}
@@ -0,0 +1,165 @@
+package spider
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// diagramCard 文本绘图卡片
+type diagramCard struct {
+	Type string `json:"type"`
+	Code string `json:"code"`
+	URL  string `json:"url"`
+}
+
+// diagramLanguages 语雀文本绘图类型对应的代码块语言
+var diagramLanguages = map[string]string{
+	"puml":     "plantuml",
+	"plantuml": "plantuml",
+	"mermaid":  "mermaid",
+	"graphviz": "dot",
+	"dot":      "dot",
+}
+
+var (
+	// imageLinkRegex 匹配远程图片
+	imageLinkRegex = regexp.MustCompile(`!\[([^\]]*)\]\((https?://[^)\s]+)\)`)
+	// diagramPathRegex 语雀渲染文本绘图的图片路径
+	diagramPathRegex = regexp.MustCompile(`/__(puml|plantuml|mermaid|graphviz)`)
+	// lakeCardRegex 匹配 Lake 文档中的卡片
+	lakeCardRegex = regexp.MustCompile(`<card\s[^>]*>`)
+	// lakeAttrRegex 匹配卡片属性
+	lakeAttrRegex = regexp.MustCompile(`([\w-]+)="([^"]*)"`)
+)
+
+// isDiagramEnabled 是否启用了绘图还原
+func isDiagramEnabled(config Config) bool {
+	for _, name := range config.Transforms {
+		if strings.EqualFold(strings.TrimSpace(name), "diagrams") {
+			return true
+		}
+	}
+	return false
+}
+
+// restoreDiagrams 将 PlantUML/Mermaid/Graphviz 卡片的渲染图片还原为源码代码块.
+// 源码依次从图片 URL 片段和 Lake 原始数据中查找, 可选保留渲染图作为备用资源
+func restoreDiagrams(markdown string, ctx *TransformContext) string {
+	var lakeCards []diagramCard
+	if ctx.Doc != nil {
+		lakeCards = parseLakeDiagramCards(ctx.Doc.Content)
+	}
+	byURL := make(map[string]diagramCard, len(lakeCards))
+	for _, card := range lakeCards {
+		if card.URL != "" {
+			byURL[stripURLFragment(card.URL)] = card
+		}
+	}
+	next := 0
+
+	return mapOutsideCode(markdown, func(line string) string {
+		return imageLinkRegex.ReplaceAllStringFunc(line, func(match string) string {
+			parts := imageLinkRegex.FindStringSubmatch(match)
+			alt, imageURL := parts[1], parts[2]
+
+			// 记录绘图图片的出现顺序, 用于对应 Lake 卡片
+			index := -1
+			if diagramPathRegex.MatchString(imageURL) {
+				index = next
+				next++
+			}
+
+			card, ok := diagramFromImageURL(imageURL)
+			if !ok && index >= 0 {
+				// 片段中没有源码时按 URL 或出现顺序查找 Lake 卡片
+				if card, ok = byURL[stripURLFragment(imageURL)]; !ok && index < len(lakeCards) {
+					card, ok = lakeCards[index], true
+				}
+			}
+
+			lang, known := diagramLanguages[strings.ToLower(card.Type)]
+			if !ok || !known || strings.TrimSpace(card.Code) == "" {
+				return match
+			}
+
+			block := fmt.Sprintf("\n```%s\n%s\n```\n", lang, strings.TrimSpace(card.Code))
+			if ctx.Config.KeepDiagramImages {
+				if alt == "" {
+					alt = lang
+				}
+				block += fmt.Sprintf("\n![%s](%s)\n", alt, stripURLFragment(imageURL))
+			}
+			return block
+		})
+	})
+}
+
+// diagramFromImageURL 从图片 URL 片段中解析绘图卡片,
+// 支持 #card=puml&code=... 与 #lake_card_v2={...} 两种形式
+func diagramFromImageURL(imageURL string) (diagramCard, bool) {
+	values := parseCardFragment(imageURL)
+	if values == nil {
+		return diagramCard{}, false
+	}
+
+	if raw := values.Get("lake_card_v2"); raw != "" {
+		var card diagramCard
+		if err := json.Unmarshal([]byte(raw), &card); err == nil && card.Code != "" {
+			return card, true
+		}
+	}
+
+	cardType := values.Get("type")
+	if cardType == "" || values.Get("card") != "diagram" {
+		cardType = values.Get("card")
+	}
+	if _, ok := diagramLanguages[cardType]; ok && values.Get("code") != "" {
+		return diagramCard{Type: cardType, Code: values.Get("code"), URL: imageURL}, true
+	}
+	return diagramCard{}, false
+}
+
+// parseLakeDiagramCards 按出现顺序提取 Lake 文档中的文本绘图卡片
+func parseLakeDiagramCards(content string) []diagramCard {
+	if !strings.Contains(content, "<card") {
+		return nil
+	}
+
+	var cards []diagramCard
+	for _, tag := range lakeCardRegex.FindAllString(content, -1) {
+		attrs := make(map[string]string)
+		for _, m := range lakeAttrRegex.FindAllStringSubmatch(tag, -1) {
+			attrs[m[1]] = html.UnescapeString(m[2])
+		}
+
+		name := attrs["name"]
+		if name != "diagram" && name != "puml" && name != "mermaid" && name != "graphviz" {
+			continue
+		}
+
+		value := strings.TrimPrefix(attrs["value"], "data:")
+		decoded, err := url.PathUnescape(value)
+		if err != nil {
+			continue
+		}
+
+		var card diagramCard
+		if err := json.Unmarshal([]byte(decoded), &card); err != nil {
+			continue
+		}
+		if card.Type == "" {
+			card.Type = name
+		}
+		cards = append(cards, card)
+	}
+	return cards
+}
+
+// stripURLFragment 去掉 URL 中的锚点
+func stripURLFragment(rawURL string) string {
+	return strings.Split(rawURL, "#")[0]
+}
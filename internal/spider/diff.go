@@ -0,0 +1,224 @@
+package spider
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContextLines 统一格式差异中每个变更块保留的上下文行数
+const diffContextLines = 3
+
+// maxDiffEdits 编辑距离超过该值时不再寻找最短编辑序列, 直接视为整体替换, 避免回溯记录占用过多内存
+const maxDiffEdits = 2000
+
+// diffOp 行级编辑操作
+type diffOp struct {
+	// Kind ' ' 表示相同, '-' 表示删除, '+' 表示新增
+	Kind byte
+	Line string
+	// A, B 分别为该行在旧、新文本中的下标, 不存在时为 -1
+	A, B int
+}
+
+// DiffStat 差异统计
+type DiffStat struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+}
+
+// splitLines 按行拆分文本, 忽略末尾换行
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines 使用 Myers 算法计算两组行之间的最短编辑序列
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	// trace[d] 只保存第 d 步开始时对角线 -d..d 的位置, 回溯时用 k+d 取值
+	var trace [][]int
+
+search:
+	for d := 0; d <= max; d++ {
+		if d > maxDiffEdits {
+			return replaceLines(a, b)
+		}
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// 从终点回溯编辑路径
+	ops := make([]diffOp, 0, n+m)
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[k-1+d] < v[k+1+d]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[prevK+d]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, diffOp{Kind: ' ', Line: a[x-1], A: x - 1, B: y - 1})
+			x--
+			y--
+		}
+		if x == prevX {
+			ops = append(ops, diffOp{Kind: '+', Line: b[y-1], A: -1, B: y - 1})
+			y--
+		} else {
+			ops = append(ops, diffOp{Kind: '-', Line: a[x-1], A: x - 1, B: -1})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		ops = append(ops, diffOp{Kind: ' ', Line: a[x-1], A: x - 1, B: y - 1})
+		x--
+		y--
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// replaceLines 删除全部旧行再新增全部新行
+func replaceLines(a, b []string) []diffOp {
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for i, line := range a {
+		ops = append(ops, diffOp{Kind: '-', Line: line, A: i, B: -1})
+	}
+	for j, line := range b {
+		ops = append(ops, diffOp{Kind: '+', Line: line, A: -1, B: j})
+	}
+	return ops
+}
+
+// UnifiedDiff 生成统一格式(unified diff)的行级差异, 内容相同时返回空字符串
+func UnifiedDiff(oldText, newText, oldName, newName string) (string, DiffStat) {
+	ops := diffLines(splitLines(oldText), splitLines(newText))
+
+	var stat DiffStat
+	for _, op := range ops {
+		switch op.Kind {
+		case '+':
+			stat.Added++
+		case '-':
+			stat.Removed++
+		}
+	}
+	if stat.Added == 0 && stat.Removed == 0 {
+		return "", stat
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", oldName, newName))
+
+	for start := 0; start < len(ops); {
+		// 找到下一个变更
+		for start < len(ops) && ops[start].Kind == ' ' {
+			start++
+		}
+		if start >= len(ops) {
+			break
+		}
+
+		// 向后合并间隔不超过两倍上下文的变更
+		end := start
+		for i := start; i < len(ops); i++ {
+			if ops[i].Kind != ' ' {
+				end = i
+			} else if i-end > 2*diffContextLines {
+				break
+			}
+		}
+
+		from := start - diffContextLines
+		if from < 0 {
+			from = 0
+		}
+		to := end + diffContextLines + 1
+		if to > len(ops) {
+			to = len(ops)
+		}
+
+		hunk := ops[from:to]
+		oldStart, newStart, oldCount, newCount := -1, -1, 0, 0
+		for _, op := range hunk {
+			if op.A >= 0 {
+				if oldStart < 0 {
+					oldStart = op.A
+				}
+				oldCount++
+			}
+			if op.B >= 0 {
+				if newStart < 0 {
+					newStart = op.B
+				}
+				newCount++
+			}
+		}
+		b.WriteString(fmt.Sprintf("@@ -%s +%s @@\n", hunkRange(oldStart, oldCount, ops, from, true), hunkRange(newStart, newCount, ops, from, false)))
+		for _, op := range hunk {
+			b.WriteByte(op.Kind)
+			b.WriteString(op.Line)
+			b.WriteByte('\n')
+		}
+
+		start = to
+	}
+
+	return b.String(), stat
+}
+
+// hunkRange 格式化变更块的行号范围, 行数为 0 时行号取前一行
+func hunkRange(start, count int, ops []diffOp, from int, old bool) string {
+	if count == 0 {
+		line := 0
+		for i := from - 1; i >= 0; i-- {
+			if old && ops[i].A >= 0 {
+				line = ops[i].A + 1
+				break
+			}
+			if !old && ops[i].B >= 0 {
+				line = ops[i].B + 1
+				break
+			}
+		}
+		return fmt.Sprintf("%d,0", line)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,180 @@
+package spider
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// lakeSheetContent 语雀表格/数据表的原始内容
+type lakeSheetContent struct {
+	Format string `json:"format"`
+	// Sheet 经 deflate 压缩后以 latin1 字符串编码的工作表数据
+	Sheet string `json:"sheet"`
+}
+
+// lakeSheet 解压后的单个工作表
+type lakeSheet struct {
+	Name string                                `json:"name"`
+	Data map[string]map[string]json.RawMessage `json:"data"`
+}
+
+// writeSheetDocument 将表格文档导出为 CSV, 多个工作表分别保存;
+// 无法解析时保留原始 JSON. 返回主文件名和其余工作表的文件名.
+// 文件名在 names 中登记, 避免与同目录的其他文件重名
+func writeSheetDocument(dirPath, parentPath, baseName, content string, names *nameRegistry) (string, []string, error) {
+	sheets, err := decodeLakeSheets(content)
+	if err != nil || len(sheets) == 0 {
+		fileName := names.claim(parentPath, baseName, ".json") + ".json"
+		return fileName, nil, os.WriteFile(filepath.Join(dirPath, fileName), []byte(content), 0644)
+	}
+
+	var mainFile string
+	var extra []string
+	for i, sheet := range sheets {
+		name := baseName
+		if len(sheets) > 1 {
+			sheetName := sheet.Name
+			if sheetName == "" {
+				sheetName = fmt.Sprintf("Sheet%d", i+1)
+			}
+			name = truncateName(fmt.Sprintf("%s - %s", baseName, cleanFileName(sheetName)), maxNameBytes)
+		}
+		fileName := names.claim(parentPath, name, ".csv") + ".csv"
+		if err := writeSheetCSV(filepath.Join(dirPath, fileName), sheet); err != nil {
+			return "", nil, err
+		}
+		if mainFile == "" {
+			mainFile = fileName
+		} else {
+			extra = append(extra, fileName)
+		}
+	}
+	return mainFile, extra, nil
+}
+
+// sheetAssets 计算附属工作表文件的校验和
+func sheetAssets(dirPath, parentPath string, files []string) ([]Asset, error) {
+	var assets []Asset
+	for _, name := range files {
+		sum, size, err := fileChecksum(filepath.Join(dirPath, name))
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, Asset{Path: joinSlash(parentPath, name), SHA256: sum, Size: size})
+	}
+	return assets, nil
+}
+
+// decodeLakeSheets 解析 lakesheet 内容
+func decodeLakeSheets(content string) ([]lakeSheet, error) {
+	var raw lakeSheetContent
+	if err := json.Unmarshal([]byte(content), &raw); err != nil {
+		return nil, err
+	}
+	if raw.Sheet == "" {
+		return nil, fmt.Errorf("表格内容为空")
+	}
+
+	// 每个字符对应压缩数据中的一个字节
+	compressed := make([]byte, 0, len(raw.Sheet))
+	for _, r := range raw.Sheet {
+		if r > 0xff {
+			return nil, fmt.Errorf("无法识别的表格编码")
+		}
+		compressed = append(compressed, byte(r))
+	}
+
+	data, err := inflate(compressed)
+	if err != nil {
+		return nil, err
+	}
+
+	var sheets []lakeSheet
+	if err := json.Unmarshal(data, &sheets); err != nil {
+		return nil, err
+	}
+	return sheets, nil
+}
+
+// inflate 解压 zlib 或原始 deflate 数据
+func inflate(data []byte) ([]byte, error) {
+	if r, err := zlib.NewReader(bytes.NewReader(data)); err == nil {
+		defer r.Close()
+		return io.ReadAll(r)
+	}
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// writeSheetCSV 按行列顺序写出工作表, 行列范围以实际有数据的单元格为准
+func writeSheetCSV(path string, sheet lakeSheet) error {
+	rowCount, colCount := 0, 0
+	for rowKey, cols := range sheet.Data {
+		if row, err := strconv.Atoi(rowKey); err == nil && row+1 > rowCount {
+			rowCount = row + 1
+		}
+		for colKey := range cols {
+			if col, err := strconv.Atoi(colKey); err == nil && col+1 > colCount {
+				colCount = col + 1
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	for row := 0; row < rowCount; row++ {
+		record := make([]string, colCount)
+		for col := 0; col < colCount; col++ {
+			record[col] = cellText(sheet.Data[strconv.Itoa(row)][strconv.Itoa(col)])
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// cellText 提取单元格的显示值
+func cellText(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+
+	var cell struct {
+		V json.RawMessage `json:"v"`
+		M json.RawMessage `json:"m"`
+	}
+	if err := json.Unmarshal(raw, &cell); err != nil || (cell.V == nil && cell.M == nil) {
+		return jsonScalar(raw)
+	}
+	if cell.M != nil {
+		return jsonScalar(cell.M)
+	}
+	return jsonScalar(cell.V)
+}
+
+// jsonScalar 将 JSON 值转换为文本
+func jsonScalar(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	if string(raw) == "null" {
+		return ""
+	}
+	return string(raw)
+}
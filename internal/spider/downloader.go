@@ -1,12 +1,15 @@
 package spider
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
-	"time"
 )
 
 // Downloader 文档下载器
@@ -14,6 +17,11 @@ type Downloader struct {
 	fetcher    *Fetcher
 	outputPath string
 	config     Config
+	logger     *slog.Logger
+	// names 知识库的文件名登记表, 用于为表格的工作表等额外文件分配不冲突的名称
+	names *nameRegistry
+	// onImages 图片进度回调, found 为新发现的图片数, done 为新处理完的图片数
+	onImages func(found, done int)
 }
 
 // NewDownloader 创建新的下载器
@@ -22,91 +30,256 @@ func NewDownloader(cookie string, outputPath string, config Config) *Downloader
 		fetcher:    NewFetcher(cookie, config),
 		outputPath: outputPath,
 		config:     config,
+		logger:     slog.Default(),
 	}
 }
 
-// SaveDocument 保存文档
-func (d *Downloader) SaveDocument(bookID int, slug, title, parentPath string) error {
+// SavedDocument 已保存文档的信息
+type SavedDocument struct {
+	// Path 主文件路径(相对知识库根目录, 以 / 分隔)
+	Path string
+	// Doc 文档元数据(不含正文)
+	Doc *DocData
+	// Assets 文档引用的已下载资源
+	Assets []Asset
+	// CommentsPath 评论旁路文件路径, 未保存或追加到正文时为空
+	CommentsPath string
+	// HistoryPath 历史版本目录, 未保存时为空
+	HistoryPath string
+}
+
+// Asset 已下载的资源文件
+type Asset struct {
+	SourceURL string `json:"sourceUrl"`
+	// Path 本地路径(相对知识库根目录)
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// SaveDocument 保存文档, 按文档类型导出为 Markdown、CSV 或 JSON.
+// docPath 为规划好的 Markdown 路径, 实际扩展名取决于文档类型
+func (d *Downloader) SaveDocument(bookID int, slug, docPath string) (*SavedDocument, error) {
 	// 获取文档内容
 	docData, err := d.fetcher.FetchDocument(bookID, slug)
 	if err != nil {
-		return fmt.Errorf("获取文档失败: %w", err)
+		return nil, fmt.Errorf("获取文档失败: %w", err)
+	}
+
+	// 非 Markdown 文档以及含文本绘图的文档需要以原始模式重新获取内容
+	needsContent := docData.Type != "" && docData.Type != DocTypeDoc
+	if isDiagramEnabled(d.config) && diagramPathRegex.MatchString(docData.SourceCode) {
+		needsContent = true
+	}
+	if needsContent && docData.Content == "" {
+		raw, err := d.fetcher.FetchDocumentRaw(bookID, slug)
+		switch {
+		case err == nil:
+			docData.Content = raw.Content
+		case docData.Type != "" && docData.Type != DocTypeDoc:
+			// 表格、画板没有原始内容就无法导出
+			return nil, fmt.Errorf("获取文档原始内容失败: %w", err)
+		default:
+			d.logger.Warn("获取文档原始内容失败, 文本绘图将保留源码", "doc", docData.Title, "err", err)
+		}
 	}
 
 	// 创建文件路径
-	filePath := filepath.Join(d.outputPath, parentPath, cleanFileName(title)+".md")
+	parentPath, baseName := splitDocPath(docPath)
+	dirPath := filepath.Join(d.outputPath, filepath.FromSlash(parentPath))
 
 	// 确保目录存在
-	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
-		return fmt.Errorf("创建目录失败: %w", err)
+	if err := os.MkdirAll(dirPath, 0755); err != nil {
+		return nil, fmt.Errorf("创建目录失败: %w", err)
+	}
+
+	var fileName, commentsFile string
+	var assets []Asset
+	switch docData.Type {
+	case DocTypeSheet, DocTypeTable:
+		var sheetFiles []string
+		fileName, sheetFiles, err = writeSheetDocument(dirPath, parentPath, baseName, docData.Content, d.names)
+		if err == nil {
+			// 其余工作表作为文档的附属文件记录到清单
+			assets, err = sheetAssets(dirPath, parentPath, sheetFiles)
+		}
+	case DocTypeBoard:
+		fileName = baseName + ".board.json"
+		err = os.WriteFile(filepath.Join(dirPath, fileName), []byte(docData.Content), 0644)
+	default:
+		// 转换语雀特有语法后下载并替换图片链接
+		fileName = baseName + ".md"
+		markdown := applyTransforms(docData.SourceCode, &TransformContext{Doc: docData, Config: d.config})
+		markdown, assets = d.processImages(markdown, dirPath)
+		if d.config.Comments != CommentsOff && docData.ID != 0 {
+			if commentsFile, err = d.saveComments(docData.ID, docData.Title, dirPath, baseName, &markdown); err != nil {
+				d.logger.Warn("评论下载失败", "doc", docData.Title, "err", err)
+			}
+		}
+		err = os.WriteFile(filepath.Join(dirPath, fileName), []byte(markdown), 0644)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("写入文件失败: %w", err)
 	}
 
-	// 下载并替换图片链接
-	markdown := d.processImages(docData.SourceCode, filepath.Dir(filePath))
+	// 保存历史版本
+	var historyPath string
+	if d.config.HistoryVersions != 0 && docData.ID != 0 && fileName == baseName+".md" {
+		if historyPath, err = d.saveHistory(docData.ID, joinSlash(parentPath, fileName)); err != nil {
+			d.logger.Warn("历史版本下载失败", "doc", docData.Title, "err", err)
+		}
+	}
 
-	// 写入文件
-	if err := os.WriteFile(filePath, []byte(markdown), 0644); err != nil {
-		return fmt.Errorf("写入文件失败: %w", err)
+	// 非 Markdown 文档的评论保存为旁路文件
+	if d.config.Comments != CommentsOff && docData.ID != 0 && fileName != baseName+".md" {
+		if commentsFile, err = d.saveComments(docData.ID, docData.Title, dirPath, baseName, nil); err != nil {
+			d.logger.Warn("评论下载失败", "doc", docData.Title, "err", err)
+		}
 	}
 
-	return nil
+	meta := *docData
+	meta.SourceCode = ""
+	meta.Content = ""
+	saved := &SavedDocument{
+		Path:   joinSlash(parentPath, fileName),
+		Doc:    &meta,
+		Assets: assets,
+	}
+	if commentsFile != "" {
+		saved.CommentsPath = joinSlash(parentPath, commentsFile)
+	}
+	saved.HistoryPath = historyPath
+	return saved, nil
 }
 
-// processImages 处理 Markdown 中的图片
-func (d *Downloader) processImages(markdown, docDir string) string {
-	// 创建 assets 目录
+// SaveLink 将外链节点保存为 Markdown 链接或 .url 快捷方式
+func (d *Downloader) SaveLink(title, target, docPath string) (*SavedDocument, error) {
+	parentPath, baseName := splitDocPath(docPath)
+	dirPath := filepath.Join(d.outputPath, filepath.FromSlash(parentPath))
+	if err := os.MkdirAll(dirPath, 0755); err != nil {
+		return nil, fmt.Errorf("创建目录失败: %w", err)
+	}
+
+	var fileName, content string
+	if d.config.LinkFormat == LinkFormatURL {
+		fileName = baseName + ".url"
+		content = fmt.Sprintf("[InternetShortcut]\r\nURL=%s\r\n", target)
+	} else {
+		fileName = baseName + ".md"
+		content = fmt.Sprintf("# %s\n\n[%s](%s)\n", title, escapeLinkText(title), target)
+	}
+
+	if err := os.WriteFile(filepath.Join(dirPath, fileName), []byte(content), 0644); err != nil {
+		return nil, fmt.Errorf("写入文件失败: %w", err)
+	}
+
+	return &SavedDocument{Path: joinSlash(parentPath, fileName)}, nil
+}
+
+// processImages 处理 Markdown 中的图片, 返回替换后的内容和已下载的资源
+func (d *Downloader) processImages(markdown, docDir string) (string, []Asset) {
+	// 创建 assets 目录, Obsidian 模式下统一存放到附件目录
 	assetsDir := filepath.Join(docDir, "assets")
+	obsidian := d.config.ExportFormat == ExportFormatObsidian
+	if obsidian {
+		assetsDir = filepath.Join(d.outputPath, attachmentDir(d.config))
+	}
 	os.MkdirAll(assetsDir, 0755)
 
-	// 正则匹配图片链接
-	imgRegex := regexp.MustCompile(`!\[.*?\]\((.*?)\)`)
-
-	result := imgRegex.ReplaceAllStringFunc(markdown, func(match string) string {
-		// 提取 URL
-		urlRegex := regexp.MustCompile(`\((.*?)\)`)
-		urlMatches := urlRegex.FindStringSubmatch(match)
-		if len(urlMatches) < 2 {
-			return match
+	// 先统计需要下载的图片数, 与下面的替换使用同一判断
+	if d.onImages != nil {
+		found := 0
+		for _, match := range markdownImageRegex.FindAllString(markdown, -1) {
+			if _, ok := imageSourceURL(match); ok {
+				found++
+			}
 		}
+		if found > 0 {
+			d.onImages(found, 0)
+		}
+	}
 
-		imageURL := urlMatches[1]
-
-		// 跳过非 HTTP 链接
-		if !strings.HasPrefix(imageURL, "http") {
+	var assets []Asset
+	result := markdownImageRegex.ReplaceAllStringFunc(markdown, func(match string) string {
+		imageURL, ok := imageSourceURL(match)
+		if !ok {
 			return match
 		}
 
-		// 移除 URL 中的锚点
-		imageURL = strings.Split(imageURL, "#")[0]
-
-		// 生成图片文件名
-		timestamp := time.Now().UnixMilli()
-		ext := filepath.Ext(imageURL)
-		if ext == "" {
+		// 按 URL 哈希生成图片文件名, 同一图片重复同步时路径保持不变
+		urlSum := sha256.Sum256([]byte(imageURL))
+		imageID := "image-" + hex.EncodeToString(urlSum[:6])
+		ext := filepath.Ext(strings.Split(imageURL, "?")[0])
+		if ext == "" || len(ext) > 6 {
 			ext = ".png"
 		}
-		imageName := fmt.Sprintf("image-%d%s", timestamp, ext)
-		imageName = cleanFileName(imageName)
+		imageName := cleanFileName(imageID + ext)
 
 		// 下载图片
 		imageData, err := d.fetcher.DownloadImage(imageURL)
+		if d.onImages != nil {
+			d.onImages(0, 1)
+		}
 		if err != nil {
-			fmt.Printf("图片下载失败 %s: %v\n", imageURL, err)
+			d.logger.Warn("图片下载失败", "url", imageURL, "err", err)
 			return match
 		}
 
 		// 保存图片
 		imagePath := filepath.Join(assetsDir, imageName)
 		if err := os.WriteFile(imagePath, imageData, 0644); err != nil {
-			fmt.Printf("保存图片失败 %s: %v\n", imagePath, err)
+			d.logger.Error("保存图片失败", "path", imagePath, "err", err)
 			return match
 		}
 
+		relPath, _ := filepath.Rel(d.outputPath, imagePath)
+		dataSum := sha256.Sum256(imageData)
+		assets = append(assets, Asset{
+			SourceURL: imageURL,
+			Path:      filepath.ToSlash(relPath),
+			SHA256:    hex.EncodeToString(dataSum[:]),
+			Size:      int64(len(imageData)),
+		})
+
 		// 返回新的 Markdown 链接
-		return fmt.Sprintf("![image-%d](./assets/%s)", timestamp, imageName)
+		if obsidian {
+			return fmt.Sprintf("![[%s]]", imageName)
+		}
+		return fmt.Sprintf("![%s](./assets/%s)", imageID, imageName)
 	})
 
-	return result
+	return result, assets
+}
+
+var (
+	// markdownImageRegex 匹配 Markdown 图片
+	markdownImageRegex = regexp.MustCompile(`!\[.*?\]\((.*?)\)`)
+	// imageURLRegex 提取图片语法中的地址
+	imageURLRegex = regexp.MustCompile(`\((.*?)\)`)
+)
+
+// imageSourceURL 提取需要下载的图片地址并去掉锚点, 非 HTTP 链接返回 false
+func imageSourceURL(match string) (string, bool) {
+	m := imageURLRegex.FindStringSubmatch(match)
+	if len(m) < 2 || !strings.HasPrefix(m[1], "http") {
+		return "", false
+	}
+	return strings.Split(m[1], "#")[0], true
+}
+
+// splitDocPath 拆分规划路径为所在目录与不含扩展名的文件名
+func splitDocPath(docPath string) (string, string) {
+	dir, file := path.Split(docPath)
+	return strings.TrimSuffix(dir, "/"), strings.TrimSuffix(file, path.Ext(file))
+}
+
+// attachmentDir 返回 Obsidian 附件目录
+func attachmentDir(config Config) string {
+	dir := strings.Trim(filepath.Clean(config.AttachmentDir), `/\.`)
+	if dir == "" {
+		return "attachments"
+	}
+	return dir
 }
 
 // cleanFileName 清理文件名中的非法字符
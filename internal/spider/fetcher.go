@@ -1,13 +1,16 @@
 package spider
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"regexp"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
@@ -16,38 +19,114 @@ import (
 // Fetcher 网络请求处理器
 type Fetcher struct {
 	client *http.Client
+	// cookie 凭据原文, 切换站点时据此重建 Cookie jar
 	cookie string
 	config Config
+	// baseURL API 所在站点, 不以 / 结尾
+	baseURL string
+	// received 已接收的响应字节数
+	received atomic.Int64
+	// ctx 请求所属任务的上下文, 任务取消时中止请求及限速等待
+	ctx context.Context
 }
 
-// NewFetcher 创建新的 Fetcher
+// NewFetcher 创建新的 Fetcher, cookie 可以是 Cookie 请求头或导入的 Cookie 列表
 func NewFetcher(cookie string, config Config) *Fetcher {
-	return &Fetcher{
-		client: &http.Client{
-			Timeout: time.Duration(config.Timeout) * time.Second,
-		},
-		cookie: cookie,
-		config: config,
+	baseURL := resolveBaseURL(config.BaseURL, "")
+
+	// 代理或证书配置有误时, 错误在第一次请求时返回
+	var transport http.RoundTripper
+	if t, err := newTransport(config); err != nil {
+		transport = errTransport{fmt.Errorf("网络配置错误: %w", err)}
+	} else {
+		// 超时只计算单次请求, 限速等待与重试不占用超时时间
+		transport = &limitedTransport{base: t, timeout: time.Duration(config.Timeout) * time.Second}
 	}
+
+	f := &Fetcher{
+		cookie:  cookie,
+		config:  config,
+		baseURL: baseURL,
+	}
+	f.client = &http.Client{
+		Transport: &countingTransport{base: transport, received: &f.received},
+		Jar:       newCookieJar(cookie, baseURL),
+	}
+	return f
+}
+
+// BytesReceived 返回已接收的响应字节数
+func (f *Fetcher) BytesReceived() int64 {
+	return f.received.Load()
+}
+
+// setBaseURL 切换 API 所在站点, 未指定域名的 Cookie 随之归属新站点
+func (f *Fetcher) setBaseURL(baseURL string) {
+	f.baseURL = baseURL
+	f.client.Jar = newCookieJar(f.cookie, baseURL)
+}
+
+// setContext 设置后续请求使用的上下文
+func (f *Fetcher) setContext(ctx context.Context) {
+	f.ctx = ctx
+}
+
+// context 请求使用的上下文, 未设置时不会被取消
+func (f *Fetcher) context() context.Context {
+	if f.ctx == nil {
+		return context.Background()
+	}
+	return f.ctx
+}
+
+// setCookie 替换凭据
+func (f *Fetcher) setCookie(cookie string) {
+	f.cookie = cookie
+	f.client.Jar = newCookieJar(cookie, f.baseURL)
+}
+
+// ErrSessionExpired 登录已失效
+var ErrSessionExpired = errors.New("登录已失效, 请更新 Cookie")
+
+// checkSession 识别登录失效的响应: 401 或被重定向到登录页
+func checkSession(resp *http.Response) error {
+	if resp.StatusCode == http.StatusUnauthorized {
+		return ErrSessionExpired
+	}
+	if resp.Request != nil && strings.HasPrefix(resp.Request.URL.Path, "/login") {
+		return ErrSessionExpired
+	}
+	return nil
+}
+
+// resolveBaseURL 确定 API 所在站点: 优先使用配置, 其次使用知识库地址所在的站点
+func resolveBaseURL(baseURL, bookURL string) string {
+	if baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/"); baseURL != "" {
+		return baseURL
+	}
+	if u, err := url.Parse(bookURL); err == nil && u.Scheme != "" && u.Host != "" {
+		return u.Scheme + "://" + u.Host
+	}
+	return DefaultBaseURL
 }
 
 // FetchBookTitle 获取知识库标题
 func (f *Fetcher) FetchBookTitle(rawURL string) (string, error) {
-	req, err := http.NewRequest("GET", rawURL, nil)
+	req, err := http.NewRequestWithContext(f.context(), "GET", rawURL, nil)
 	if err != nil {
 		return "", err
 	}
 
-	if f.cookie != "" {
-		req.Header.Set("Cookie", f.cookie)
-	}
-
 	resp, err := f.client.Do(req)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
+	if err := checkSession(resp); err != nil {
+		return "", err
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("请求失败,状态码: %d", resp.StatusCode)
 	}
@@ -76,21 +155,21 @@ func (f *Fetcher) FetchBookTitle(rawURL string) (string, error) {
 
 // FetchBookData 获取知识库数据
 func (f *Fetcher) FetchBookData(rawURL string) (*YuqueData, error) {
-	req, err := http.NewRequest("GET", rawURL, nil)
+	req, err := http.NewRequestWithContext(f.context(), "GET", rawURL, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	if f.cookie != "" {
-		req.Header.Set("Cookie", f.cookie)
-	}
-
 	resp, err := f.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if err := checkSession(resp); err != nil {
+		return nil, err
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
@@ -118,23 +197,33 @@ func (f *Fetcher) FetchBookData(rawURL string) (*YuqueData, error) {
 
 // FetchDocument 获取文档内容
 func (f *Fetcher) FetchDocument(bookID int, slug string) (*DocData, error) {
-	apiURL := fmt.Sprintf("https://www.yuque.com/api/docs/%s?book_id=%d&merge_dynamic_data=false&mode=markdown", slug, bookID)
+	apiURL := fmt.Sprintf("%s/api/docs/%s?book_id=%d&merge_dynamic_data=false&mode=markdown", f.baseURL, slug, bookID)
+	return f.fetchDocData(apiURL)
+}
+
+// FetchDocumentRaw 获取文档原始内容(表格、画板等非 Markdown 文档)
+func (f *Fetcher) FetchDocumentRaw(bookID int, slug string) (*DocData, error) {
+	apiURL := fmt.Sprintf("%s/api/docs/%s?book_id=%d&merge_dynamic_data=false", f.baseURL, slug, bookID)
+	return f.fetchDocData(apiURL)
+}
 
-	req, err := http.NewRequest("GET", apiURL, nil)
+// fetchDocData 请求文档 API
+func (f *Fetcher) fetchDocData(apiURL string) (*DocData, error) {
+	req, err := http.NewRequestWithContext(f.context(), "GET", apiURL, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	if f.cookie != "" {
-		req.Header.Set("Cookie", f.cookie)
-	}
-
 	resp, err := f.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if err := checkSession(resp); err != nil {
+		return nil, err
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("文档下载失败,状态码: %d", resp.StatusCode)
 	}
@@ -147,15 +236,154 @@ func (f *Fetcher) FetchDocument(bookID int, slug string) (*DocData, error) {
 	return &docResp.Data, nil
 }
 
-// DownloadImage 下载图片
-func (f *Fetcher) DownloadImage(imageURL string) ([]byte, error) {
-	req, err := http.NewRequest("GET", imageURL, nil)
+// FetchDocList 获取知识库的文档列表(不含正文), 用于增量同步时比较更新时间
+func (f *Fetcher) FetchDocList(bookID int) ([]DocData, error) {
+	apiURL := fmt.Sprintf("%s/api/docs?book_id=%d", f.baseURL, bookID)
+
+	req, err := http.NewRequestWithContext(f.context(), "GET", apiURL, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	if f.cookie != "" {
-		req.Header.Set("Cookie", f.cookie)
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkSession(resp); err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("文档列表获取失败,状态码: %d", resp.StatusCode)
+	}
+
+	var listResp struct {
+		Data []DocData `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&listResp); err != nil {
+		return nil, err
+	}
+
+	return listResp.Data, nil
+}
+
+// FetchComments 获取文档评论(含回复与划词评论)
+func (f *Fetcher) FetchComments(docID int) ([]Comment, error) {
+	apiURL := fmt.Sprintf("%s/api/comments/floor?commentable_type=Doc&commentable_id=%d&include_section=true&include_to_user=true", f.baseURL, docID)
+
+	req, err := http.NewRequestWithContext(f.context(), "GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkSession(resp); err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("评论获取失败,状态码: %d", resp.StatusCode)
+	}
+
+	var commentResp struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&commentResp); err != nil {
+		return nil, err
+	}
+
+	// data 可能直接是评论数组, 也可能包含 comments 字段
+	var comments []Comment
+	if err := json.Unmarshal(commentResp.Data, &comments); err == nil {
+		return comments, nil
+	}
+	var wrapped struct {
+		Comments []Comment `json:"comments"`
+	}
+	if err := json.Unmarshal(commentResp.Data, &wrapped); err != nil {
+		return nil, err
+	}
+	return wrapped.Comments, nil
+}
+
+// FetchDocVersions 获取文档的历史版本列表
+func (f *Fetcher) FetchDocVersions(docID int) ([]DocVersion, error) {
+	apiURL := fmt.Sprintf("%s/api/doc_versions?doc_id=%d", f.baseURL, docID)
+
+	req, err := http.NewRequestWithContext(f.context(), "GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkSession(resp); err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("历史版本获取失败,状态码: %d", resp.StatusCode)
+	}
+
+	var versionResp struct {
+		Data []DocVersion `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&versionResp); err != nil {
+		return nil, err
+	}
+
+	return versionResp.Data, nil
+}
+
+// FetchDocVersion 获取单个历史版本的内容
+func (f *Fetcher) FetchDocVersion(versionID int) (*DocVersion, error) {
+	apiURL := fmt.Sprintf("%s/api/doc_versions/%d?mode=markdown", f.baseURL, versionID)
+
+	req, err := http.NewRequestWithContext(f.context(), "GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkSession(resp); err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("历史版本下载失败,状态码: %d", resp.StatusCode)
+	}
+
+	var versionResp struct {
+		Data DocVersion `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&versionResp); err != nil {
+		return nil, err
+	}
+
+	return &versionResp.Data, nil
+}
+
+// DownloadImage 下载图片
+func (f *Fetcher) DownloadImage(imageURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(f.context(), "GET", imageURL, nil)
+	if err != nil {
+		return nil, err
 	}
 
 	resp, err := f.client.Do(req)
@@ -0,0 +1,97 @@
+package spider
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// gitAuthor 自动提交使用的作者
+var gitAuthor = object.Signature{Name: "yuque-spider", Email: "yuque-spider@localhost"}
+
+// commitBookDir 将知识库目录提交到其中的 git 仓库, 仓库不存在时自动初始化, 没有变更时不提交
+func commitBookDir(bookDir string, tree *BookTree, report *ChangeReport) error {
+	repo, err := git.PlainOpen(bookDir)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		repo, err = git.PlainInit(bookDir, false)
+	}
+	if err != nil {
+		return fmt.Errorf("打开 git 仓库失败: %w", err)
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("打开 git 工作区失败: %w", err)
+	}
+	if err := worktree.AddWithOptions(&git.AddOptions{All: true}); err != nil {
+		return fmt.Errorf("暂存变更失败: %w", err)
+	}
+
+	status, err := worktree.Status()
+	if err != nil {
+		return fmt.Errorf("读取 git 状态失败: %w", err)
+	}
+	if status.IsClean() || onlyGeneratedChanges(repo, status) {
+		return nil
+	}
+
+	author := gitAuthor
+	author.When = time.Now()
+	if _, err := worktree.Commit(gitCommitMessage(tree, report), &git.CommitOptions{Author: &author}); err != nil {
+		return fmt.Errorf("提交失败: %w", err)
+	}
+	return nil
+}
+
+// onlyGeneratedChanges 清单和变更报告每次同步都会重写, 只有它们变化时不提交. 仓库还没有提交时总是提交
+func onlyGeneratedChanges(repo *git.Repository, status git.Status) bool {
+	if _, err := repo.Head(); err != nil {
+		return false
+	}
+	for path, file := range status {
+		if file.Staging == git.Unmodified {
+			continue
+		}
+		if path != ManifestFileName && path != ChangeReportFileName {
+			return false
+		}
+	}
+	return true
+}
+
+// gitCommitMessage 根据变更报告生成提交说明, 首次同步时统计文档数量
+func gitCommitMessage(tree *BookTree, report *ChangeReport) string {
+	if report == nil {
+		saved := 0
+		for _, item := range tree.Items {
+			if item.Saved {
+				saved++
+			}
+		}
+		return fmt.Sprintf("同步 %s: 新增 %d 篇文档\n", tree.Title, saved)
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("同步 %s: 新增 %d, 修改 %d, 重命名 %d, 删除 %d\n",
+		tree.Title, report.Count(ChangeAdded), report.Count(ChangeModified), report.Count(ChangeRenamed), report.Count(ChangeRemoved)))
+	if len(report.Changes) > 0 {
+		b.WriteString("\n")
+	}
+	for _, change := range report.Changes {
+		switch change.Kind {
+		case ChangeAdded:
+			b.WriteString(fmt.Sprintf("A %s\n", change.Path))
+		case ChangeModified:
+			b.WriteString(fmt.Sprintf("M %s\n", change.Path))
+		case ChangeRenamed:
+			b.WriteString(fmt.Sprintf("R %s -> %s\n", change.OldPath, change.Path))
+		case ChangeRemoved:
+			b.WriteString(fmt.Sprintf("D %s\n", change.OldPath))
+		}
+	}
+	return b.String()
+}
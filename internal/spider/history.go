@@ -0,0 +1,176 @@
+package spider
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HistoryDirName 历史版本目录(位于知识库根目录)
+const HistoryDirName = ".history"
+
+// historyIndexName 每个文档历史目录中的版本索引
+const historyIndexName = "versions.json"
+
+// DocVersion 文档历史版本
+type DocVersion struct {
+	ID         int    `json:"id"`
+	CreatedAt  string `json:"created_at"`
+	SourceCode string `json:"sourcecode"`
+	Content    string `json:"content"`
+	User       struct {
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	} `json:"user"`
+}
+
+// Author 版本作者的显示名
+func (v *DocVersion) Author() string {
+	if v.User.Name != "" {
+		return v.User.Name
+	}
+	return v.User.Login
+}
+
+// HistoryVersion 已保存的历史版本
+type HistoryVersion struct {
+	ID        int    `json:"id"`
+	CreatedAt string `json:"createdAt"`
+	Author    string `json:"author"`
+	// Path 版本文件路径(相对知识库根目录)
+	Path string `json:"path"`
+}
+
+// historyDir 文档历史版本目录(相对知识库根目录)
+func historyDir(docPath string) string {
+	return joinSlash(HistoryDirName, docID(docPath))
+}
+
+// saveHistory 保存文档的历史版本, 已保存过的版本不会重复下载.
+// 配置的 HistoryVersions 小于 0 时保存全部版本, 否则保存最近 N 个
+func (d *Downloader) saveHistory(docID int, docPath string) (string, error) {
+	versions, err := d.fetcher.FetchDocVersions(docID)
+	if err != nil {
+		return "", err
+	}
+	if len(versions) == 0 {
+		return "", nil
+	}
+
+	// 按时间从新到旧排序后截取
+	sort.SliceStable(versions, func(i, j int) bool { return versions[i].CreatedAt > versions[j].CreatedAt })
+	if limit := d.config.HistoryVersions; limit > 0 && len(versions) > limit {
+		versions = versions[:limit]
+	}
+
+	relDir := historyDir(docPath)
+	dir := filepath.Join(d.outputPath, filepath.FromSlash(relDir))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	saved := make([]HistoryVersion, 0, len(versions))
+	keep := map[string]bool{historyIndexName: true}
+	for _, version := range versions {
+		fileName := historyFileName(version)
+		path := filepath.Join(dir, fileName)
+		keep[fileName] = true
+
+		if _, err := os.Stat(path); err != nil {
+			detail, err := d.fetcher.FetchDocVersion(version.ID)
+			if err != nil {
+				d.logger.Warn("历史版本下载失败", "doc", docPath, "version", version.ID, "err", err)
+				continue
+			}
+			body := detail.SourceCode
+			if body == "" {
+				body = detail.Content
+			}
+			if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+				return "", err
+			}
+		}
+
+		saved = append(saved, HistoryVersion{
+			ID:        version.ID,
+			CreatedAt: version.CreatedAt,
+			Author:    version.Author(),
+			Path:      joinSlash(relDir, fileName),
+		})
+	}
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, historyIndexName), data, 0644); err != nil {
+		return "", err
+	}
+
+	// 删除已不在最近 N 个版本之内的旧版本文件
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || keep[entry.Name()] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+			d.logger.Warn("删除旧历史版本失败", "doc", docPath, "file", entry.Name(), "err", err)
+		}
+	}
+	return relDir, nil
+}
+
+// historyFileName 以时间、版本号和作者命名版本文件
+func historyFileName(version DocVersion) string {
+	stamp := version.CreatedAt
+	if t, err := time.Parse(time.RFC3339, version.CreatedAt); err == nil {
+		stamp = t.Local().Format("20060102-150405")
+	}
+	name := fmt.Sprintf("%s-v%d", stamp, version.ID)
+	if author := strings.TrimSpace(version.Author()); author != "" {
+		name += "-" + author
+	}
+	return safeFileName(name) + ".md"
+}
+
+// ReadHistory 读取文档已保存的历史版本, 从新到旧排列
+func ReadHistory(bookDir, docPath string) ([]HistoryVersion, error) {
+	data, err := os.ReadFile(filepath.Join(bookDir, filepath.FromSlash(historyDir(docPath)), historyIndexName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []HistoryVersion{}, nil
+		}
+		return nil, err
+	}
+	var versions []HistoryVersion
+	if err := json.Unmarshal(data, &versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// DiffFiles 比较知识库中的两个文件(如两个历史版本或历史版本与当前文档), 返回统一格式差异
+func DiffFiles(bookDir, oldPath, newPath string) (string, error) {
+	for _, p := range []string{oldPath, newPath} {
+		if !filepath.IsLocal(filepath.FromSlash(p)) {
+			return "", fmt.Errorf("路径不在知识库目录内: %s", p)
+		}
+	}
+	oldData, err := os.ReadFile(filepath.Join(bookDir, filepath.FromSlash(oldPath)))
+	if err != nil {
+		return "", err
+	}
+	newData, err := os.ReadFile(filepath.Join(bookDir, filepath.FromSlash(newPath)))
+	if err != nil {
+		return "", err
+	}
+	diff, _ := UnifiedDiff(string(oldData), string(newData), oldPath, newPath)
+	return diff, nil
+}
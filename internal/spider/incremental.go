@@ -0,0 +1,132 @@
+package spider
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// incrementalSync 增量同步状态: 上次同步的清单条目与本次文档列表中的更新时间
+type incrementalSync struct {
+	entries map[string]ManifestEntry
+	assets  map[string]Asset
+	// updated 文档 slug 对应的最新更新时间
+	updated map[string]string
+}
+
+func newIncrementalSync(prev *snapshot, docs []DocData) *incrementalSync {
+	inc := &incrementalSync{
+		entries: make(map[string]ManifestEntry),
+		assets:  make(map[string]Asset),
+		updated: make(map[string]string),
+	}
+	for _, entry := range prev.manifest.Entries {
+		if entry.Path != "" {
+			inc.entries[entry.UUID] = entry
+		}
+	}
+	for _, asset := range prev.manifest.Assets {
+		inc.assets[asset.Path] = asset
+	}
+	for _, doc := range docs {
+		inc.updated[doc.Slug] = doc.UpdatedAt
+	}
+	return inc
+}
+
+// reuse 文档自上次同步后未更新且本地文件完好时沿用上次的结果, 返回是否沿用
+func (inc *incrementalSync) reuse(bookDir string, item *TOCItem) bool {
+	entry, ok := inc.entries[item.Node.UUID]
+	if !ok || entry.UpdatedAt == "" || inc.updated[item.Node.URL] != entry.UpdatedAt {
+		return false
+	}
+
+	// 目录结构或命名规则变化导致路径不同时重新下载
+	oldDir, oldBase := splitDocPath(entry.Path)
+	newDir, newBase := splitDocPath(item.DocPath)
+	if oldDir != newDir || oldBase != newBase {
+		return false
+	}
+
+	// 文件被修改或删除时重新下载
+	sum, _, err := fileChecksum(filepath.Join(bookDir, filepath.FromSlash(entry.Path)))
+	if err != nil || sum != entry.SHA256 {
+		return false
+	}
+	for _, path := range entry.Assets {
+		if _, err := os.Stat(filepath.Join(bookDir, filepath.FromSlash(path))); err != nil {
+			return false
+		}
+	}
+
+	restoreItem(item, entry, inc.assets)
+	return true
+}
+
+// carryForward 本次没有保存的文档(下载失败或任务取消前未处理到)沿用上次同步的文件,
+// 使清单中保留其记录, 避免下次同步当作新增或被当作过期文件清理
+func carryForward(bookDir string, prev *snapshot, tree *BookTree) {
+	if prev == nil {
+		return
+	}
+	entries := make(map[string]ManifestEntry)
+	for _, entry := range prev.manifest.Entries {
+		if entry.Path != "" {
+			entries[entry.UUID] = entry
+		}
+	}
+	assets := make(map[string]Asset)
+	for _, asset := range prev.manifest.Assets {
+		assets[asset.Path] = asset
+	}
+	used := make(map[string]bool)
+	for _, item := range tree.Items {
+		if item.Saved {
+			used[item.DocPath] = true
+		}
+	}
+
+	for _, item := range tree.Items {
+		if !item.IsDoc() || item.Saved {
+			continue
+		}
+		entry, ok := entries[item.Node.UUID]
+		// 旧文件已被本次保存的其他文档占用或已不存在时不再沿用
+		if !ok || used[entry.Path] {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(bookDir, filepath.FromSlash(entry.Path))); err != nil {
+			continue
+		}
+		restoreItem(item, entry, assets)
+		used[entry.Path] = true
+	}
+}
+
+// restoreItem 按上次同步的清单条目恢复节点的保存结果
+func restoreItem(item *TOCItem, entry ManifestEntry, assets map[string]Asset) {
+	item.Saved = true
+	item.Reused = true
+	item.DocPath = entry.Path
+	item.Doc = &DocData{
+		ID:          entry.DocID,
+		Slug:        entry.Slug,
+		Title:       entry.Title,
+		Type:        entry.DocType,
+		Description: entry.Description,
+		CreatedAt:   entry.CreatedAt,
+		UpdatedAt:   entry.UpdatedAt,
+	}
+	item.Assets = nil
+	for _, path := range entry.Assets {
+		if asset, ok := assets[path]; ok {
+			item.Assets = append(item.Assets, asset)
+		}
+	}
+	item.CommentsPath = entry.Comments
+	item.HistoryPath = entry.History
+	item.SavedAt = time.Now()
+	if entry.DownloadedAt != nil {
+		item.SavedAt = *entry.DownloadedAt
+	}
+}
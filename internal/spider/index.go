@@ -0,0 +1,241 @@
+package spider
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// IndexWriter 根据目录结构生成索引文件
+type IndexWriter interface {
+	// FileName 索引文件名(位于知识库根目录)
+	FileName() string
+	// Render 生成索引内容
+	Render(tree *BookTree) ([]byte, error)
+}
+
+// indexWriters 已注册的索引格式
+var indexWriters = map[string]IndexWriter{
+	"summary": summaryIndex{},
+	"readme":  readmeIndex{},
+	"json":    jsonIndex{},
+	"opml":    opmlIndex{},
+}
+
+// defaultIndexFormats 未配置时生成的索引
+var defaultIndexFormats = []string{"summary"}
+
+// IndexFormatNames 返回支持的索引格式
+func IndexFormatNames() []string {
+	names := make([]string, 0, len(indexWriters))
+	for name := range indexWriters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// reservedIndexNames 知识库根目录下为索引、清单和变更报告保留的文件名
+func reservedIndexNames() []string {
+	names := []string{ManifestFileName, ChangeReportFileName}
+	for _, writer := range indexWriters {
+		names = append(names, writer.FileName())
+	}
+	return names
+}
+
+// writeIndexes 生成配置中启用的索引, formats 为 nil 时使用默认格式
+func writeIndexes(bookDir string, tree *BookTree, formats []string) error {
+	if formats == nil {
+		formats = defaultIndexFormats
+	}
+
+	for _, name := range formats {
+		writer, ok := indexWriters[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return fmt.Errorf("未知的索引格式: %s", name)
+		}
+		data, err := writer.Render(tree)
+		if err != nil {
+			return fmt.Errorf("生成 %s 失败: %w", writer.FileName(), err)
+		}
+		if err := os.WriteFile(filepath.Join(bookDir, writer.FileName()), data, 0644); err != nil {
+			return fmt.Errorf("保存 %s 失败: %w", writer.FileName(), err)
+		}
+	}
+	return nil
+}
+
+// hasContent 节点本身或其子孙是否有已保存的文档
+func hasContent(item *TOCItem) bool {
+	if item.Saved {
+		return true
+	}
+	for _, child := range item.Children {
+		if hasContent(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// summaryIndex GitBook 风格的 SUMMARY.md
+type summaryIndex struct{}
+
+func (summaryIndex) FileName() string { return "SUMMARY.md" }
+
+func (summaryIndex) Render(tree *BookTree) ([]byte, error) {
+	var b strings.Builder
+	b.WriteString("# Summary\n\n")
+
+	var walk func(items []*TOCItem, depth int)
+	walk = func(items []*TOCItem, depth int) {
+		for _, item := range items {
+			if !hasContent(item) {
+				continue
+			}
+
+			indent := strings.Repeat("  ", depth)
+			if item.Saved {
+				b.WriteString(fmt.Sprintf("%s* [%s](%s)\n", indent, escapeLinkText(item.Title), escapeURLPath(item.DocPath)))
+			} else {
+				// 没有对应文档的分组只输出标题
+				b.WriteString(fmt.Sprintf("%s* %s\n", indent, item.Title))
+			}
+			walk(item.Children, depth+1)
+		}
+	}
+	walk(tree.Roots, 0)
+
+	return []byte(b.String()), nil
+}
+
+// readmeIndex 带文档摘要的 README.md 目录
+type readmeIndex struct{}
+
+func (readmeIndex) FileName() string { return "README.md" }
+
+func (readmeIndex) Render(tree *BookTree) ([]byte, error) {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("# %s\n\n", tree.Title))
+	if tree.Description != "" {
+		b.WriteString(tree.Description + "\n\n")
+	}
+	b.WriteString("## 目录\n\n")
+
+	tree.Walk(func(item *TOCItem, depth int) {
+		if !hasContent(item) {
+			return
+		}
+		indent := strings.Repeat("  ", depth)
+		if !item.Saved {
+			b.WriteString(fmt.Sprintf("%s- **%s**\n", indent, item.Title))
+			return
+		}
+
+		b.WriteString(fmt.Sprintf("%s- [%s](%s)", indent, escapeLinkText(item.Title), escapeURLPath(item.DocPath)))
+		if item.Doc != nil && item.Doc.Description != "" {
+			b.WriteString(" - " + strings.Join(strings.Fields(item.Doc.Description), " "))
+		}
+		b.WriteString("\n")
+	})
+
+	return []byte(b.String()), nil
+}
+
+// jsonIndexNode JSON 目录中的节点
+type jsonIndexNode struct {
+	Title    string           `json:"title"`
+	Type     string           `json:"type"`
+	Slug     string           `json:"slug,omitempty"`
+	Path     string           `json:"path,omitempty"`
+	Children []*jsonIndexNode `json:"children,omitempty"`
+}
+
+// jsonIndex 嵌套结构的 index.json
+type jsonIndex struct{}
+
+func (jsonIndex) FileName() string { return "index.json" }
+
+func (jsonIndex) Render(tree *BookTree) ([]byte, error) {
+	var build func(items []*TOCItem) []*jsonIndexNode
+	build = func(items []*TOCItem) []*jsonIndexNode {
+		nodes := make([]*jsonIndexNode, 0, len(items))
+		for _, item := range items {
+			node := &jsonIndexNode{
+				Title:    item.Title,
+				Type:     item.Node.Type,
+				Children: build(item.Children),
+			}
+			if item.IsDoc() && item.Node.Type != TOCTypeLink {
+				node.Slug = item.Node.URL
+			}
+			if item.Saved {
+				node.Path = item.DocPath
+			}
+			nodes = append(nodes, node)
+		}
+		return nodes
+	}
+
+	return json.MarshalIndent(map[string]any{
+		"title":       tree.Title,
+		"description": tree.Description,
+		"toc":         build(tree.Roots),
+	}, "", "  ")
+}
+
+// opmlOutline OPML 大纲节点
+type opmlOutline struct {
+	Text     string        `xml:"text,attr"`
+	Type     string        `xml:"type,attr,omitempty"`
+	URL      string        `xml:"url,attr,omitempty"`
+	Children []opmlOutline `xml:"outline"`
+}
+
+// opmlIndex OPML 大纲 index.opml
+type opmlIndex struct{}
+
+func (opmlIndex) FileName() string { return "index.opml" }
+
+func (opmlIndex) Render(tree *BookTree) ([]byte, error) {
+	var build func(items []*TOCItem) []opmlOutline
+	build = func(items []*TOCItem) []opmlOutline {
+		outlines := make([]opmlOutline, 0, len(items))
+		for _, item := range items {
+			outline := opmlOutline{
+				Text:     item.Title,
+				Children: build(item.Children),
+			}
+			if item.Saved {
+				outline.Type = "link"
+				outline.URL = escapeURLPath(item.DocPath)
+			}
+			outlines = append(outlines, outline)
+		}
+		return outlines
+	}
+
+	doc := struct {
+		XMLName xml.Name `xml:"opml"`
+		Version string   `xml:"version,attr"`
+		Head    struct {
+			Title string `xml:"title"`
+		} `xml:"head"`
+		Body struct {
+			Outlines []opmlOutline `xml:"outline"`
+		} `xml:"body"`
+	}{Version: "2.0"}
+	doc.Head.Title = tree.Title
+	doc.Body.Outlines = build(tree.Roots)
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
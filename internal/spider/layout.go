@@ -0,0 +1,339 @@
+package spider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LayoutWriter 静态站点项目布局生成器
+type LayoutWriter interface {
+	// Write 根据目录结构在知识库目录中生成项目文件
+	Write(bookDir string, tree *BookTree) error
+}
+
+// layoutWriters 已注册的布局生成器
+var layoutWriters = map[string]LayoutWriter{
+	"mdbook":     mdBookLayout{},
+	"docusaurus": docusaurusLayout{},
+	"vitepress":  vitePressLayout{},
+	"hugo":       hugoLayout{},
+}
+
+// LayoutNames 返回支持的布局名称
+func LayoutNames() []string {
+	names := make([]string, 0, len(layoutWriters))
+	for name := range layoutWriters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CheckLayouts 检查布局名称是否都受支持, 在添加任务时调用以便尽早报错
+func CheckLayouts(layouts []string) error {
+	for _, name := range layouts {
+		if _, ok := layoutWriters[strings.ToLower(strings.TrimSpace(name))]; !ok {
+			return fmt.Errorf("未知的布局: %s, 支持 %s", name, strings.Join(LayoutNames(), ", "))
+		}
+	}
+	return nil
+}
+
+// writeLayouts 生成配置中启用的全部布局
+func writeLayouts(bookDir string, tree *BookTree, layouts []string) error {
+	for _, name := range layouts {
+		writer, ok := layoutWriters[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return fmt.Errorf("未知的布局: %s", name)
+		}
+		if err := writer.Write(bookDir, tree); err != nil {
+			return fmt.Errorf("生成 %s 布局失败: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// linkable 节点是否有可链接的本地 Markdown 文档
+func linkable(item *TOCItem) bool {
+	return item.IsDoc() && item.Saved && filepath.Ext(item.DocPath) == ".md"
+}
+
+// docID 去掉扩展名的文档路径
+func docID(docPath string) string {
+	return strings.TrimSuffix(docPath, filepath.Ext(docPath))
+}
+
+// escapeURLPath 逐段转义路径, 保留分隔符 /
+func escapeURLPath(p string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// escapeLinkText 转义 Markdown 链接文本中的方括号
+func escapeLinkText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`).Replace(s)
+}
+
+// writeFileIfMissing 仅在文件不存在时写入, 避免覆盖用户修改过的项目配置
+func writeFileIfMissing(path string, data []byte) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// mdBookLayout 生成 mdBook 项目(book.toml 与 SUMMARY.md)
+type mdBookLayout struct{}
+
+func (mdBookLayout) Write(bookDir string, tree *BookTree) error {
+	var toml strings.Builder
+	toml.WriteString("[book]\n")
+	toml.WriteString(fmt.Sprintf("title = %s\n", strconv.Quote(tree.Title)))
+	if tree.Description != "" {
+		toml.WriteString(fmt.Sprintf("description = %s\n", strconv.Quote(tree.Description)))
+	}
+	toml.WriteString("language = \"zh\"\n")
+	toml.WriteString("src = \".\"\n\n")
+	toml.WriteString("[build]\n")
+	toml.WriteString("build-dir = \"_book\"\n")
+	if err := writeFileIfMissing(filepath.Join(bookDir, "book.toml"), []byte(toml.String())); err != nil {
+		return err
+	}
+
+	// mdBook 的 SUMMARY.md 与 GitBook 格式兼容, 直接覆盖默认索引
+	var summary strings.Builder
+	summary.WriteString("# Summary\n\n")
+	tree.Walk(func(item *TOCItem, depth int) {
+		indent := strings.Repeat("  ", depth)
+		if linkable(item) {
+			summary.WriteString(fmt.Sprintf("%s- [%s](<%s>)\n", indent, escapeLinkText(item.Title), item.DocPath))
+		} else {
+			// 无对应文档的目录节点作为草稿章节
+			summary.WriteString(fmt.Sprintf("%s- [%s]()\n", indent, escapeLinkText(item.Title)))
+		}
+	})
+	return os.WriteFile(filepath.Join(bookDir, "SUMMARY.md"), []byte(summary.String()), 0644)
+}
+
+// docusaurusLayout 生成 Docusaurus 的 sidebars.js 与 _category_.json
+type docusaurusLayout struct{}
+
+func (docusaurusLayout) Write(bookDir string, tree *BookTree) error {
+	var build func(items []*TOCItem) []any
+	build = func(items []*TOCItem) []any {
+		entries := make([]any, 0, len(items))
+		for _, item := range items {
+			if len(item.Children) == 0 {
+				if linkable(item) {
+					entries = append(entries, map[string]any{
+						"type":  "doc",
+						"id":    docID(item.DocPath),
+						"label": item.Title,
+					})
+				}
+				continue
+			}
+
+			category := map[string]any{
+				"type":  "category",
+				"label": item.Title,
+				"items": build(item.Children),
+			}
+			if linkable(item) {
+				category["link"] = map[string]any{"type": "doc", "id": docID(item.DocPath)}
+			}
+			entries = append(entries, category)
+		}
+		return entries
+	}
+
+	sidebar, err := json.MarshalIndent(map[string]any{"bookSidebar": build(tree.Roots)}, "", "  ")
+	if err != nil {
+		return err
+	}
+	content := "// 由语雀知识库目录自动生成, 重新同步时会被覆盖\n" +
+		"/** @type {import('@docusaurus/plugin-content-docs').SidebarsConfig} */\n" +
+		"module.exports = " + string(sidebar) + ";\n"
+	if err := os.WriteFile(filepath.Join(bookDir, "sidebars.js"), []byte(content), 0644); err != nil {
+		return err
+	}
+
+	// 为每个目录写入分类元数据, 保持目录顺序
+	var writeCategories func(items []*TOCItem) error
+	writeCategories = func(items []*TOCItem) error {
+		for _, item := range items {
+			if item.Dir == "" {
+				continue
+			}
+			data, err := json.MarshalIndent(map[string]any{
+				"label":    item.Title,
+				"position": item.Position(items),
+			}, "", "  ")
+			if err != nil {
+				return err
+			}
+			dir := filepath.Join(bookDir, filepath.FromSlash(item.Dir))
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+			if err := os.WriteFile(filepath.Join(dir, "_category_.json"), data, 0644); err != nil {
+				return err
+			}
+			if err := writeCategories(item.Children); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return writeCategories(tree.Roots)
+}
+
+// vitePressLayout 生成 VitePress 的侧边栏配置
+type vitePressLayout struct{}
+
+func (vitePressLayout) Write(bookDir string, tree *BookTree) error {
+	var build func(items []*TOCItem) []any
+	build = func(items []*TOCItem) []any {
+		entries := make([]any, 0, len(items))
+		for _, item := range items {
+			entry := map[string]any{"text": item.Title}
+			if linkable(item) {
+				entry["link"] = "/" + escapeURLPath(docID(item.DocPath))
+			}
+			if len(item.Children) > 0 {
+				entry["collapsed"] = false
+				entry["items"] = build(item.Children)
+			} else if !linkable(item) {
+				continue
+			}
+			entries = append(entries, entry)
+		}
+		return entries
+	}
+
+	sidebar, err := json.MarshalIndent(build(tree.Roots), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	configDir := filepath.Join(bookDir, ".vitepress")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return err
+	}
+
+	content := "// 由语雀知识库目录自动生成, 重新同步时会被覆盖\n" +
+		"export default " + string(sidebar) + "\n"
+	if err := os.WriteFile(filepath.Join(configDir, "sidebar.mjs"), []byte(content), 0644); err != nil {
+		return err
+	}
+
+	title, _ := json.Marshal(tree.Title)
+	description, _ := json.Marshal(tree.Description)
+	config := "import sidebar from './sidebar.mjs'\n\n" +
+		"export default {\n" +
+		"  title: " + string(title) + ",\n" +
+		"  description: " + string(description) + ",\n" +
+		"  themeConfig: {\n" +
+		"    sidebar,\n" +
+		"  },\n" +
+		"}\n"
+	return writeFileIfMissing(filepath.Join(configDir, "config.mjs"), []byte(config))
+}
+
+// hugoLayout 生成 Hugo 的分区 _index.md 并为文档写入权重
+type hugoLayout struct{}
+
+func (hugoLayout) Write(bookDir string, tree *BookTree) error {
+	root := fmt.Sprintf("---\ntitle: %s\n---\n", strconv.Quote(tree.Title))
+	if tree.Description != "" {
+		root += "\n" + tree.Description + "\n"
+	}
+	if err := os.WriteFile(filepath.Join(bookDir, "_index.md"), []byte(root), 0644); err != nil {
+		return err
+	}
+
+	var walk func(items []*TOCItem) error
+	walk = func(items []*TOCItem) error {
+		for _, item := range items {
+			weight := item.Position(items)
+
+			if item.Dir != "" {
+				dir := filepath.Join(bookDir, filepath.FromSlash(item.Dir))
+				if err := os.MkdirAll(dir, 0755); err != nil {
+					return err
+				}
+				index := fmt.Sprintf("---\ntitle: %s\nweight: %d\n---\n", strconv.Quote(item.Title), weight)
+				if err := os.WriteFile(filepath.Join(dir, "_index.md"), []byte(index), 0644); err != nil {
+					return err
+				}
+			}
+
+			if linkable(item) {
+				if err := prependHugoFrontMatter(filepath.Join(bookDir, filepath.FromSlash(item.DocPath)), item.Title, weight); err != nil {
+					return err
+				}
+			}
+
+			if err := walk(item.Children); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(tree.Roots)
+}
+
+// prependHugoFrontMatter 为文档添加标题与权重. 已有 front matter 时补充缺少的标题,
+// 权重总是按当前目录顺序更新
+func prependHugoFrontMatter(path, title string, weight int) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	content := string(data)
+	titleLine := fmt.Sprintf("title: %s", strconv.Quote(title))
+	weightLine := fmt.Sprintf("weight: %d", weight)
+
+	if !strings.HasPrefix(content, "---\n") {
+		frontMatter := "---\n" + titleLine + "\n" + weightLine + "\n---\n\n"
+		return os.WriteFile(path, []byte(frontMatter+content), 0644)
+	}
+
+	end := strings.Index(content[4:], "\n---\n")
+	if end < 0 {
+		// front matter 没有结束标记, 不做修改
+		return nil
+	}
+	lines := strings.Split(content[4:4+end], "\n")
+	hasTitle, hasWeight := false, false
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "title:"):
+			hasTitle = true
+		case strings.HasPrefix(line, "weight:"):
+			hasWeight = true
+			lines[i] = weightLine
+		}
+	}
+	if !hasTitle {
+		lines = append(lines, titleLine)
+	}
+	if !hasWeight {
+		lines = append(lines, weightLine)
+	}
+
+	merged := "---\n" + strings.Join(lines, "\n") + content[4+end:]
+	if merged == content {
+		return nil
+	}
+	return os.WriteFile(path, []byte(merged), 0644)
+}
@@ -0,0 +1,194 @@
+package spider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ManifestFileName 下载清单文件名
+const ManifestFileName = "manifest.json"
+
+// manifestVersion 清单格式版本
+const manifestVersion = 1
+
+// Manifest 知识库下载清单, 供下游工具读取下载结果
+type Manifest struct {
+	Version     int          `json:"version"`
+	Book        ManifestBook `json:"book"`
+	GeneratedAt time.Time    `json:"generatedAt"`
+	// Entries 按目录顺序排列的全部节点, 通过 ParentUUID 和 Depth 还原层级
+	Entries []ManifestEntry `json:"entries"`
+	// Assets 全部已下载资源, 按本地路径排序
+	Assets []Asset `json:"assets"`
+}
+
+// ManifestBook 知识库元数据
+type ManifestBook struct {
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	SourceURL   string `json:"sourceUrl"`
+}
+
+// ManifestEntry 单个目录节点
+type ManifestEntry struct {
+	UUID       string `json:"uuid"`
+	ParentUUID string `json:"parentUuid,omitempty"`
+	Depth      int    `json:"depth"`
+	Title      string `json:"title"`
+	Type       string `json:"type"`
+	Slug       string `json:"slug,omitempty"`
+	DocID      int    `json:"docId,omitempty"`
+	DocType    string `json:"docType,omitempty"`
+	SourceURL  string `json:"sourceUrl,omitempty"`
+	// Description 文档摘要
+	Description string `json:"description,omitempty"`
+	// Dir 子节点所在目录
+	Dir string `json:"dir,omitempty"`
+	// Path 文档文件路径, 未成功保存的文档为空
+	Path string `json:"path,omitempty"`
+	// SHA256 文档文件的校验和
+	SHA256       string     `json:"sha256,omitempty"`
+	Size         int64      `json:"size,omitempty"`
+	CreatedAt    string     `json:"createdAt,omitempty"`
+	UpdatedAt    string     `json:"updatedAt,omitempty"`
+	DownloadedAt *time.Time `json:"downloadedAt,omitempty"`
+	// Assets 文档引用的资源路径
+	Assets []string `json:"assets,omitempty"`
+	// Comments 评论旁路文件路径
+	Comments string `json:"comments,omitempty"`
+	// History 历史版本目录
+	History string `json:"history,omitempty"`
+}
+
+// newManifest 根据目录结构生成清单, 文档校验和按磁盘上的最终内容计算
+func newManifest(bookDir string, tree *BookTree) (*Manifest, error) {
+	manifest := &Manifest{
+		Version: manifestVersion,
+		Book: ManifestBook{
+			ID:          tree.BookID,
+			Title:       tree.Title,
+			Description: tree.Description,
+			SourceURL:   tree.SourceURL,
+		},
+		GeneratedAt: time.Now(),
+		Entries:     make([]ManifestEntry, 0, len(tree.Items)),
+		Assets:      []Asset{},
+	}
+
+	assets := make(map[string]Asset)
+	var walk func(items []*TOCItem, depth int) error
+	walk = func(items []*TOCItem, depth int) error {
+		for _, item := range items {
+			entry := ManifestEntry{
+				UUID:  item.Node.UUID,
+				Depth: depth,
+				Title: item.Title,
+				Type:  item.Node.Type,
+				Dir:   item.Dir,
+			}
+			if item.Parent != nil {
+				entry.ParentUUID = item.Parent.Node.UUID
+			}
+			if item.IsDoc() {
+				entry.SourceURL = tree.DocURL(item)
+				if item.Node.Type != TOCTypeLink {
+					entry.Slug = item.Node.URL
+				}
+			}
+			if doc := item.Doc; doc != nil {
+				entry.DocID = doc.ID
+				entry.DocType = doc.Type
+				entry.Description = doc.Description
+				entry.CreatedAt = doc.CreatedAt
+				entry.UpdatedAt = doc.UpdatedAt
+			}
+			if item.Saved {
+				sum, size, err := fileChecksum(filepath.Join(bookDir, filepath.FromSlash(item.DocPath)))
+				if err != nil {
+					return err
+				}
+				savedAt := item.SavedAt
+				entry.Path = item.DocPath
+				entry.SHA256 = sum
+				entry.Size = size
+				entry.DownloadedAt = &savedAt
+				entry.Comments = item.CommentsPath
+				entry.History = item.HistoryPath
+				for _, asset := range item.Assets {
+					entry.Assets = append(entry.Assets, asset.Path)
+					assets[asset.Path] = asset
+				}
+			}
+			manifest.Entries = append(manifest.Entries, entry)
+
+			if err := walk(item.Children, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(tree.Roots, 0); err != nil {
+		return nil, err
+	}
+
+	for _, asset := range assets {
+		manifest.Assets = append(manifest.Assets, asset)
+	}
+	sort.Slice(manifest.Assets, func(i, j int) bool {
+		return manifest.Assets[i].Path < manifest.Assets[j].Path
+	})
+
+	return manifest, nil
+}
+
+// writeManifest 将清单写入知识库目录, 返回写入的清单
+func writeManifest(bookDir string, tree *BookTree) (*Manifest, error) {
+	manifest, err := newManifest(bookDir, tree)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(bookDir, ManifestFileName), data, 0644); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// ReadManifest 读取知识库目录中的清单
+func ReadManifest(bookDir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(bookDir, ManifestFileName))
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// fileChecksum 计算文件的 SHA-256 与大小
+func fileChecksum(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
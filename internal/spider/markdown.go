@@ -0,0 +1,194 @@
+package spider
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// TransformContext 转换时可用的文档信息
+type TransformContext struct {
+	// Doc 当前文档, Content 中可能包含 Lake 格式的原始数据
+	Doc    *DocData
+	Config Config
+}
+
+// MarkdownTransform 对语雀导出的 Markdown 做后处理
+type MarkdownTransform func(markdown string, ctx *TransformContext) string
+
+// 可用的转换, 按执行顺序排列
+var markdownTransforms = []struct {
+	Name      string
+	Transform MarkdownTransform
+}{
+	{"math", convertMathCards},
+	{"diagrams", restoreDiagrams},
+	{"callouts", convertCallouts},
+	{"html-cleanup", cleanupHTML},
+}
+
+// TransformNames 返回支持的 Markdown 转换名称
+func TransformNames() []string {
+	names := make([]string, 0, len(markdownTransforms))
+	for _, t := range markdownTransforms {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+// applyTransforms 按固定顺序执行配置中启用的转换
+func applyTransforms(markdown string, ctx *TransformContext) string {
+	for _, t := range markdownTransforms {
+		for _, name := range ctx.Config.Transforms {
+			if strings.EqualFold(strings.TrimSpace(name), t.Name) {
+				markdown = t.Transform(markdown, ctx)
+				break
+			}
+		}
+	}
+	return markdown
+}
+
+// fenceRegex 匹配代码块围栏
+var fenceRegex = regexp.MustCompile("^\\s*(```|~~~)")
+
+// mapOutsideCode 仅对代码块以外的行执行 fn, 代码块内容保持原样
+func mapOutsideCode(markdown string, fn func(line string) string) string {
+	lines := strings.Split(markdown, "\n")
+	var fence string
+	for i, line := range lines {
+		if m := fenceRegex.FindStringSubmatch(line); m != nil {
+			switch {
+			case fence == "":
+				fence = m[1]
+			case fence == m[1]:
+				fence = ""
+			}
+			continue
+		}
+		if fence == "" {
+			lines[i] = fn(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cardImageRegex 匹配语雀卡片渲染出的图片, 卡片数据保存在 URL 片段中
+var cardImageRegex = regexp.MustCompile(`!\[[^\]]*\]\((https?://[^)\s#]+#[^)\s]*card=[^)\s]*)\)`)
+
+// parseCardFragment 解析图片 URL 片段中的卡片参数
+func parseCardFragment(imageURL string) url.Values {
+	idx := strings.Index(imageURL, "#")
+	if idx < 0 {
+		return nil
+	}
+	values, err := url.ParseQuery(imageURL[idx+1:])
+	if err != nil {
+		return nil
+	}
+	return values
+}
+
+// convertMathCards 将公式卡片图片还原为 LaTeX, 独占一行时使用 $$ 块级公式
+func convertMathCards(markdown string, _ *TransformContext) string {
+	return mapOutsideCode(markdown, func(line string) string {
+		standalone := false
+		if m := cardImageRegex.FindStringIndex(line); m != nil {
+			standalone = strings.TrimSpace(line[:m[0]]) == "" && strings.TrimSpace(line[m[1]:]) == ""
+		}
+
+		return cardImageRegex.ReplaceAllStringFunc(line, func(match string) string {
+			card := parseCardFragment(cardImageRegex.FindStringSubmatch(match)[1])
+			if card.Get("card") != "math" || card.Get("code") == "" {
+				return match
+			}
+			code := strings.TrimSpace(card.Get("code"))
+			if standalone {
+				return fmt.Sprintf("$$\n%s\n$$", code)
+			}
+			return fmt.Sprintf("$%s$", code)
+		})
+	})
+}
+
+// calloutTypes 语雀提示块类型对应的 GitHub admonition
+var calloutTypes = map[string]string{
+	"tips":    "TIP",
+	"success": "TIP",
+	"info":    "NOTE",
+	"warning": "WARNING",
+	"danger":  "CAUTION",
+}
+
+// calloutStartRegex 匹配 ::: 容器起始行
+var calloutStartRegex = regexp.MustCompile(`^\s*:::\s*([\w-]+)\s*$`)
+
+// convertCallouts 将 ::: 提示块转换为 GitHub 风格的 admonition 引用块
+func convertCallouts(markdown string, _ *TransformContext) string {
+	lines := strings.Split(markdown, "\n")
+	result := make([]string, 0, len(lines))
+
+	var fence string
+	inCallout := false
+	for _, line := range lines {
+		if m := fenceRegex.FindStringSubmatch(line); m != nil {
+			switch {
+			case fence == "":
+				fence = m[1]
+			case fence == m[1]:
+				fence = ""
+			}
+		} else if fence == "" {
+			if !inCallout {
+				if m := calloutStartRegex.FindStringSubmatch(line); m != nil {
+					kind, ok := calloutTypes[strings.ToLower(m[1])]
+					if !ok {
+						// color1~color5 等自定义颜色块
+						kind = "NOTE"
+					}
+					result = append(result, fmt.Sprintf("> [!%s]", kind))
+					inCallout = true
+					continue
+				}
+			} else if strings.TrimSpace(line) == ":::" {
+				inCallout = false
+				continue
+			}
+		}
+
+		if inCallout {
+			if strings.TrimSpace(line) == "" {
+				line = ">"
+			} else {
+				line = "> " + line
+			}
+		}
+		result = append(result, line)
+	}
+
+	return strings.Join(result, "\n")
+}
+
+var (
+	fontTagRegex   = regexp.MustCompile(`(?i)</?font[^>]*>`)
+	anchorTagRegex = regexp.MustCompile(`(?i)<a\s+name="[^"]*"\s*>\s*</a>`)
+	brTagRegex     = regexp.MustCompile(`(?i)<br\s*/?>`)
+	trailingBRTags = regexp.MustCompile(`(?i)(\s*<br\s*/?>)+\s*$`)
+)
+
+// cleanupHTML 移除 <font>、空锚点等语雀残留标签, 并清理多余的 <br />;
+// 表格行中的 <br /> 用于单元格换行, 予以保留
+func cleanupHTML(markdown string, _ *TransformContext) string {
+	return mapOutsideCode(markdown, func(line string) string {
+		line = fontTagRegex.ReplaceAllString(line, "")
+		line = anchorTagRegex.ReplaceAllString(line, "")
+		line = strings.ReplaceAll(line, "&nbsp;", " ")
+
+		if strings.HasPrefix(strings.TrimSpace(line), "|") {
+			return line
+		}
+		line = trailingBRTags.ReplaceAllString(line, "")
+		return brTagRegex.ReplaceAllString(line, "  \n")
+	})
+}
@@ -0,0 +1,168 @@
+package spider
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/mozillazg/go-pinyin"
+	"golang.org/x/text/unicode/norm"
+)
+
+// 音译方式
+const (
+	TransliterateNone   = ""
+	TransliteratePinyin = "pinyin"
+	TransliterateASCII  = "ascii"
+)
+
+// DefaultNameTemplate 默认文件/目录命名模板
+const DefaultNameTemplate = "{title}"
+
+var (
+	// placeholderRegex 匹配 {name} 或 {name:03} 形式的占位符
+	placeholderRegex = regexp.MustCompile(`\{(\w+)(?::(\d+))?\}`)
+	// kebabSeparators 转换为 kebab-case 时视为分隔符的字符
+	kebabSeparators = regexp.MustCompile(`[\s_.,;:!?'"()\[\]{}<>|/\\+=&%#@*~^$` + "`" + `-]+`)
+)
+
+// Namer 按配置中的模板生成文件和目录名
+type Namer struct {
+	fileTemplate  string
+	dirTemplate   string
+	transliterate string
+	lowercase     bool
+	kebabCase     bool
+	pinyinArgs    pinyin.Args
+}
+
+// NewNamer 创建命名器
+func NewNamer(config Config) *Namer {
+	n := &Namer{
+		fileTemplate:  config.FileNameTemplate,
+		dirTemplate:   config.DirNameTemplate,
+		transliterate: config.Transliterate,
+		lowercase:     config.Lowercase,
+		kebabCase:     config.KebabCase,
+		pinyinArgs:    pinyin.NewArgs(),
+	}
+	if strings.TrimSpace(n.fileTemplate) == "" {
+		n.fileTemplate = DefaultNameTemplate
+	}
+	if strings.TrimSpace(n.dirTemplate) == "" {
+		n.dirTemplate = DefaultNameTemplate
+	}
+	return n
+}
+
+// FileName 生成文档文件名(不含扩展名), index 为节点在同级中的位置(从 1 开始)
+func (n *Namer) FileName(node TOCNode, index int) string {
+	return n.render(n.fileTemplate, node, index)
+}
+
+// DirName 生成目录名
+func (n *Namer) DirName(node TOCNode, index int) string {
+	return n.render(n.dirTemplate, node, index)
+}
+
+// render 展开模板并按配置转换
+func (n *Namer) render(template string, node TOCNode, index int) string {
+	name := placeholderRegex.ReplaceAllStringFunc(template, func(match string) string {
+		parts := placeholderRegex.FindStringSubmatch(match)
+		key, width := parts[1], parts[2]
+
+		switch key {
+		case "title":
+			return node.Title
+		case "slug":
+			if node.URL != "" && node.Type != TOCTypeLink {
+				return node.URL
+			}
+			return node.UUID
+		case "id":
+			if node.DocID != 0 {
+				return strconv.Itoa(node.DocID)
+			}
+			return node.UUID
+		case "uuid":
+			return node.UUID
+		case "index":
+			return formatIndex(index, width)
+		case "depth":
+			return strconv.Itoa(node.Depth)
+		}
+		return match
+	})
+
+	switch n.transliterate {
+	case TransliteratePinyin:
+		name = n.toPinyin(name)
+	case TransliterateASCII:
+		name = toASCII(n.toPinyin(name))
+	}
+	if n.kebabCase {
+		name = strings.Trim(kebabSeparators.ReplaceAllString(name, "-"), "-")
+	}
+	if n.lowercase {
+		name = strings.ToLower(name)
+	}
+
+	name = safeFileName(name)
+	if name == "" {
+		name = safeFileName(node.UUID)
+	}
+	return name
+}
+
+// formatIndex 按宽度格式化序号, 宽度以 0 开头时补零
+func formatIndex(index int, width string) string {
+	if width == "" {
+		return strconv.Itoa(index)
+	}
+	w, _ := strconv.Atoi(width)
+	if strings.HasPrefix(width, "0") {
+		return fmt.Sprintf("%0*d", w, index)
+	}
+	return fmt.Sprintf("%*d", w, index)
+}
+
+// toPinyin 将汉字转换为不带声调的拼音, 相邻音节以空格分隔
+func (n *Namer) toPinyin(s string) string {
+	var b strings.Builder
+	prevHan := false
+	for _, r := range s {
+		if !unicode.Is(unicode.Han, r) {
+			if prevHan && !unicode.IsSpace(r) {
+				b.WriteByte(' ')
+			}
+			b.WriteRune(r)
+			prevHan = false
+			continue
+		}
+
+		syllables := pinyin.SinglePinyin(r, n.pinyinArgs)
+		if len(syllables) == 0 {
+			b.WriteRune(r)
+			continue
+		}
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), " ") {
+			b.WriteByte(' ')
+		}
+		b.WriteString(syllables[0])
+		prevHan = true
+	}
+	return b.String()
+}
+
+// toASCII 去掉变音符号并丢弃其余非 ASCII 字符
+func toASCII(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		if r <= unicode.MaxASCII && unicode.IsPrint(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,210 @@
+package spider
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// markdownLinkRegex 匹配非图片的 Markdown 链接
+var markdownLinkRegex = regexp.MustCompile(`(^|[^!])\[([^\]]*)\]\(([^)\s]+)\)`)
+
+// tagInvalidChars Obsidian 标签中不允许出现的字符
+var tagInvalidChars = regexp.MustCompile(`[\s#,\[\]{}()"'!?.:;]+`)
+
+// writeObsidianVault 将已下载的知识库转换为 Obsidian 仓库:
+// 站内链接改写为 [[wikilink]], 文档添加 front matter, 目录节点生成同名 folder note
+func writeObsidianVault(bookDir string, tree *BookTree) error {
+	bySlug := make(map[string]*TOCItem)
+	docPaths := make(map[string]bool)
+	for _, item := range tree.Items {
+		if linkable(item) {
+			bySlug[item.Node.URL] = item
+			docPaths[item.DocPath] = true
+		}
+	}
+	book := bookLinkPrefix(tree.SourceURL)
+
+	for _, item := range tree.Items {
+		// 沿用的文档上次已转换过
+		if !linkable(item) || item.Reused {
+			continue
+		}
+
+		path := filepath.Join(bookDir, filepath.FromSlash(item.DocPath))
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		content := convertWikiLinks(string(data), book, bySlug)
+		content = obsidianFrontMatter(tree, item) + content
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+
+	for _, item := range tree.Items {
+		// 与文档重名时不覆盖文档
+		if item.Dir == "" || docPaths[folderNotePath(item)] {
+			continue
+		}
+		if err := writeFolderNote(bookDir, item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// convertWikiLinks 将指向同一知识库文档的链接改写为 wikilink
+func convertWikiLinks(markdown, book string, bySlug map[string]*TOCItem) string {
+	return markdownLinkRegex.ReplaceAllStringFunc(markdown, func(match string) string {
+		parts := markdownLinkRegex.FindStringSubmatch(match)
+		prefix, text, target := parts[1], parts[2], parts[3]
+
+		item := bySlug[linkSlug(target, book)]
+		if item == nil {
+			return match
+		}
+
+		if text == "" {
+			text = item.Title
+		}
+		return fmt.Sprintf("%s[[%s|%s]]", prefix, docID(item.DocPath), text)
+	})
+}
+
+// bookLinkPrefix 知识库地址中的 group/book 路径
+func bookLinkPrefix(sourceURL string) string {
+	u, err := url.Parse(sourceURL)
+	if err != nil {
+		return ""
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(segments) < 2 {
+		return ""
+	}
+	return segments[0] + "/" + segments[1]
+}
+
+// linkSlug 从指向 book(group/book) 的语雀文档链接中提取文档 slug, 其他链接返回空
+func linkSlug(target, book string) string {
+	if book == "" {
+		return ""
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return ""
+	}
+	if u.Host != "" && !strings.HasSuffix(u.Host, "yuque.com") {
+		return ""
+	}
+	if u.Host == "" && !strings.HasPrefix(u.Path, "/") {
+		return ""
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(segments) != 3 || !strings.EqualFold(segments[0]+"/"+segments[1], book) {
+		return ""
+	}
+	return segments[2]
+}
+
+// obsidianFrontMatter 生成包含标签与语雀元数据的 front matter
+func obsidianFrontMatter(tree *BookTree, item *TOCItem) string {
+	var b strings.Builder
+	b.WriteString("---\n")
+	b.WriteString(fmt.Sprintf("title: %s\n", strconv.Quote(item.Title)))
+
+	b.WriteString("tags:\n")
+	for _, tag := range obsidianTags(tree, item) {
+		b.WriteString(fmt.Sprintf("  - %s\n", strconv.Quote(tag)))
+	}
+
+	b.WriteString(fmt.Sprintf("yuque_book: %s\n", strconv.Quote(tree.Title)))
+	b.WriteString(fmt.Sprintf("yuque_slug: %s\n", strconv.Quote(item.Node.URL)))
+	if doc := item.Doc; doc != nil {
+		if doc.ID != 0 {
+			b.WriteString(fmt.Sprintf("yuque_id: %d\n", doc.ID))
+		}
+		if doc.Description != "" {
+			b.WriteString(fmt.Sprintf("description: %s\n", strconv.Quote(doc.Description)))
+		}
+		if doc.CreatedAt != "" {
+			b.WriteString(fmt.Sprintf("created: %s\n", strconv.Quote(doc.CreatedAt)))
+		}
+		if doc.UpdatedAt != "" {
+			b.WriteString(fmt.Sprintf("updated: %s\n", strconv.Quote(doc.UpdatedAt)))
+		}
+		if doc.WordCount > 0 {
+			b.WriteString(fmt.Sprintf("word_count: %d\n", doc.WordCount))
+		}
+	}
+	b.WriteString("---\n\n")
+	return b.String()
+}
+
+// obsidianTags 以知识库和上级目录作为标签
+func obsidianTags(tree *BookTree, item *TOCItem) []string {
+	tags := []string{"yuque"}
+	if tag := obsidianTag(tree.Title); tag != "" {
+		tags = append(tags, tag)
+	}
+
+	var ancestors []string
+	for parent := item.Parent; parent != nil; parent = parent.Parent {
+		if tag := obsidianTag(parent.Title); tag != "" {
+			ancestors = append([]string{tag}, ancestors...)
+		}
+	}
+	if len(ancestors) > 0 {
+		// 嵌套标签保留目录层级
+		tags = append(tags, strings.Join(ancestors, "/"))
+	}
+	return tags
+}
+
+// obsidianTag 清理标签中的非法字符
+func obsidianTag(title string) string {
+	return strings.Trim(tagInvalidChars.ReplaceAllString(title, "-"), "-/")
+}
+
+// writeFolderNote 为目录节点生成同名 folder note, 列出子节点链接
+func writeFolderNote(bookDir string, item *TOCItem) error {
+	var b strings.Builder
+	b.WriteString("---\n")
+	b.WriteString(fmt.Sprintf("title: %s\n", strconv.Quote(item.Title)))
+	b.WriteString("tags:\n  - \"yuque/folder\"\n")
+	b.WriteString("---\n\n")
+
+	if linkable(item) {
+		// 目录本身也是文档时嵌入其正文
+		b.WriteString(fmt.Sprintf("![[%s]]\n\n", docID(item.DocPath)))
+	}
+
+	for _, child := range item.Children {
+		switch {
+		case linkable(child):
+			b.WriteString(fmt.Sprintf("- [[%s|%s]]\n", docID(child.DocPath), child.Title))
+		case child.Dir != "":
+			b.WriteString(fmt.Sprintf("- [[%s|%s]]\n", docID(folderNotePath(child)), child.Title))
+		}
+	}
+
+	path := filepath.Join(bookDir, filepath.FromSlash(folderNotePath(item)))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// folderNotePath folder note 的路径: 目录内与目录同名的笔记
+func folderNotePath(item *TOCItem) string {
+	dir := strings.TrimSuffix(item.Dir, "/")
+	return joinSlash(dir, filepath.Base(dir)+".md")
+}
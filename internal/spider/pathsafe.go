@@ -0,0 +1,108 @@
+package spider
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// maxNameBytes 文件名主体的最大字节数, 为扩展名和去重后缀预留空间
+const maxNameBytes = 200
+
+// windowsReservedNames Windows 保留的设备名
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM0": true, "COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT0": true, "LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// safeFileName 生成在常见文件系统上都合法的文件名主体:
+// 去掉控制字符和结尾的点与空格, 避开 Windows 保留名, 超长时截断并附加哈希
+func safeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, cleanFileName(name))
+	name = strings.TrimRight(strings.TrimSpace(name), ". ")
+
+	base := name
+	if idx := strings.Index(base, "."); idx >= 0 {
+		base = base[:idx]
+	}
+	if windowsReservedNames[strings.ToUpper(strings.TrimSpace(base))] {
+		name = "_" + name
+	}
+
+	return truncateName(name, maxNameBytes)
+}
+
+// truncateName 超过 limit 字节时按字符边界截断, 并附加原名称的短哈希以保持唯一
+func truncateName(name string, limit int) string {
+	if len(name) <= limit {
+		return name
+	}
+
+	sum := sha1.Sum([]byte(name))
+	suffix := "-" + hex.EncodeToString(sum[:4])
+
+	cut := limit - len(suffix)
+	for cut > 0 && !utf8.RuneStart(name[cut]) {
+		cut--
+	}
+	return strings.TrimRight(name[:cut], ". ") + suffix
+}
+
+// nameRegistry 记录每个目录下已占用的名称, 按目录顺序为重名节点分配后缀
+type nameRegistry struct {
+	used map[string]bool
+}
+
+func newNameRegistry() *nameRegistry {
+	return &nameRegistry{used: make(map[string]bool)}
+}
+
+// claimAll 在 dir 下为一组扩展名同时占用名称, 任一扩展名冲突时依次尝试 name-2, name-3 ...
+// 用于文档与其评论等旁路文件共用的文件名主体
+func (r *nameRegistry) claimAll(dir, name string, exts ...string) string {
+	if r == nil {
+		return name
+	}
+	candidate := name
+	for i := 2; ; i++ {
+		free := true
+		for _, ext := range exts {
+			if r.used[r.key(dir, candidate+ext)] {
+				free = false
+				break
+			}
+		}
+		if free {
+			for _, ext := range exts {
+				r.used[r.key(dir, candidate+ext)] = true
+			}
+			return candidate
+		}
+		suffix := fmt.Sprintf("-%d", i)
+		candidate = truncateName(name, maxNameBytes-len(suffix)) + suffix
+	}
+}
+
+// key 登记表中的键, 忽略大小写和 Unicode 规范化差异
+func (r *nameRegistry) key(dir, name string) string {
+	return strings.ToLower(norm.NFC.String(joinSlash(dir, name)))
+}
+
+// claim 在 dir 下占用名称, 与已有名称冲突时依次尝试 name-2, name-3 ...
+// 比较时忽略大小写和 Unicode 规范化差异, 兼容 macOS 与 Windows 文件系统
+func (r *nameRegistry) claim(dir, name, ext string) string {
+	return r.claimAll(dir, name, ext)
+}
@@ -0,0 +1,272 @@
+package spider
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 全局限速参数: 每个站点的请求速率(次/秒)在 minRate 与 maxRate 之间自适应
+const (
+	defaultRate = 2.0
+	maxRate     = 5.0
+	minRate     = 0.1
+	rateBurst   = 3.0
+	// rateIncrease 每次成功请求后增加的速率
+	rateIncrease = 0.05
+	// throttleRetries 被限流时的重试次数
+	throttleRetries = 3
+	// maxRetryAfter Retry-After 的最长等待时间
+	maxRetryAfter = time.Minute
+)
+
+// HostRate 站点当前的限速状态
+type HostRate struct {
+	Host string `json:"host"`
+	// Rate 当前允许的请求速率(次/秒)
+	Rate    float64 `json:"rate"`
+	MaxRate float64 `json:"maxRate"`
+	// Throttled 被限流后尚未恢复到最高速率
+	Throttled       bool      `json:"throttled"`
+	ThrottleCount   int       `json:"throttleCount"`
+	LastThrottledAt time.Time `json:"lastThrottledAt,omitempty"`
+	// PausedUntil 服务端要求的暂停截止时间(Retry-After)
+	PausedUntil time.Time `json:"pausedUntil,omitempty"`
+}
+
+// hostLimiter 单个站点的令牌桶, 遇到 429/503 时速率减半, 之后每次成功请求线性恢复
+type hostLimiter struct {
+	mu            sync.Mutex
+	host          string
+	rate          float64
+	tokens        float64
+	last          time.Time
+	pausedUntil   time.Time
+	throttleCount int
+	lastThrottled time.Time
+	lastNotified  time.Time
+}
+
+// rateLimiter 进程内共享的限速器, 所有 Fetcher 的请求按站点限速
+type rateLimiter struct {
+	mu       sync.Mutex
+	hosts    map[string]*hostLimiter
+	listener func(HostRate)
+}
+
+var limiter = &rateLimiter{hosts: make(map[string]*hostLimiter)}
+
+// SetRateLimitListener 设置限速变化的回调, 被限流时立即通知, 恢复过程中每秒最多通知一次
+func SetRateLimitListener(listener func(HostRate)) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	limiter.listener = listener
+}
+
+// RateLimits 返回各站点当前的限速状态
+func RateLimits() []HostRate {
+	limiter.mu.Lock()
+	hosts := make([]*hostLimiter, 0, len(limiter.hosts))
+	for _, h := range limiter.hosts {
+		hosts = append(hosts, h)
+	}
+	limiter.mu.Unlock()
+
+	rates := make([]HostRate, 0, len(hosts))
+	for _, h := range hosts {
+		h.mu.Lock()
+		rates = append(rates, h.state())
+		h.mu.Unlock()
+	}
+	sort.Slice(rates, func(i, j int) bool { return rates[i].Host < rates[j].Host })
+	return rates
+}
+
+// host 获取站点的令牌桶
+func (l *rateLimiter) host(host string) *hostLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h, ok := l.hosts[host]
+	if !ok {
+		h = &hostLimiter{host: host, rate: defaultRate, tokens: rateBurst}
+		l.hosts[host] = h
+	}
+	return h
+}
+
+// notify 通知限速变化
+func (l *rateLimiter) notify(rate HostRate) {
+	l.mu.Lock()
+	listener := l.listener
+	l.mu.Unlock()
+	if listener != nil {
+		listener(rate)
+	}
+}
+
+// state 当前状态, 调用方需持有锁
+func (h *hostLimiter) state() HostRate {
+	rate := HostRate{
+		Host:            h.host,
+		Rate:            h.rate,
+		MaxRate:         maxRate,
+		Throttled:       h.rate < maxRate && h.throttleCount > 0,
+		ThrottleCount:   h.throttleCount,
+		LastThrottledAt: h.lastThrottled,
+	}
+	if h.pausedUntil.After(time.Now()) {
+		rate.PausedUntil = h.pausedUntil
+	}
+	return rate
+}
+
+// wait 取得一个令牌, 令牌不足或服务端要求暂停时等待
+func (h *hostLimiter) wait(ctx context.Context) error {
+	h.mu.Lock()
+	now := time.Now()
+	if !h.last.IsZero() {
+		h.tokens += now.Sub(h.last).Seconds() * h.rate
+		if h.tokens > rateBurst {
+			h.tokens = rateBurst
+		}
+	}
+	h.last = now
+	h.tokens--
+
+	// 令牌可以透支, 透支部分按当前速率折算为等待时间
+	var delay time.Duration
+	if h.tokens < 0 {
+		delay = time.Duration(-h.tokens / h.rate * float64(time.Second))
+	}
+	if d := h.pausedUntil.Sub(now); d > delay {
+		delay = d
+	}
+	h.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// observe 根据响应调整速率, 返回是否被限流
+func (h *hostLimiter) observe(resp *http.Response) bool {
+	throttled := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+
+	h.mu.Lock()
+	now := time.Now()
+	notify := false
+	if throttled {
+		// 并发请求可能同时被限流, 一秒内只减速一次
+		if now.Sub(h.lastThrottled) >= time.Second {
+			h.rate /= 2
+			if h.rate < minRate {
+				h.rate = minRate
+			}
+		}
+		h.tokens = min(h.tokens, 0)
+		h.throttleCount++
+		h.lastThrottled = now
+		if d := retryAfter(resp, now); d > 0 {
+			h.pausedUntil = now.Add(d)
+		}
+		notify = true
+		slog.Warn("请求被限流, 降低速率", "host", h.host, "status", resp.StatusCode, "rate", h.rate)
+	} else if h.rate < maxRate {
+		h.rate += rateIncrease
+		if h.rate > maxRate {
+			h.rate = maxRate
+		}
+		notify = now.Sub(h.lastNotified) >= time.Second || h.rate == maxRate
+	}
+	var state HostRate
+	if notify {
+		h.lastNotified = now
+		state = h.state()
+	}
+	h.mu.Unlock()
+
+	if notify {
+		limiter.notify(state)
+	}
+	return throttled
+}
+
+// retryAfter 解析 Retry-After 响应头, 支持秒数和 HTTP 日期
+func retryAfter(resp *http.Response, now time.Time) time.Duration {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	var d time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		d = time.Duration(seconds) * time.Second
+	} else if t, err := http.ParseTime(value); err == nil {
+		d = t.Sub(now)
+	}
+	return min(d, maxRetryAfter)
+}
+
+// limitedTransport 按站点限速的 RoundTripper, 被限流的无请求体请求会在降速后重试.
+// timeout 为单次请求(含读取响应体)的超时时间, 不包括限速等待, 为 0 时不限制
+type limitedTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	h := limiter.host(req.URL.Host)
+	for attempt := 0; ; attempt++ {
+		if err := h.wait(req.Context()); err != nil {
+			return nil, err
+		}
+		resp, err := t.send(req)
+		if err != nil {
+			return nil, err
+		}
+		if !h.observe(resp) || attempt >= throttleRetries || req.Body != nil {
+			return resp, nil
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+}
+
+// send 发送一次请求, 超时计时持续到响应体关闭
+func (t *limitedTransport) send(req *http.Request) (*http.Response, error) {
+	if t.timeout <= 0 {
+		return t.base.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody 关闭响应体时释放请求的超时计时
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,144 @@
+package spider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// User 当前登录用户
+type User struct {
+	ID    int    `json:"id"`
+	Login string `json:"login"`
+	Name  string `json:"name"`
+}
+
+// SessionInfo Cookie 校验结果
+type SessionInfo struct {
+	LoggedIn bool  `json:"loggedIn"`
+	User     *User `json:"user,omitempty"`
+	// BookChecked 是否检查了知识库访问权限
+	BookChecked    bool   `json:"bookChecked"`
+	BookAccessible bool   `json:"bookAccessible"`
+	BookTitle      string `json:"bookTitle,omitempty"`
+	BookDocs       int    `json:"bookDocs,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// FetchCurrentUser 获取 Cookie 对应的登录用户, 未登录时返回 ErrSessionExpired
+func (f *Fetcher) FetchCurrentUser() (*User, error) {
+	req, err := http.NewRequestWithContext(f.context(), "GET", f.baseURL+"/api/mine", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkSession(resp); err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("用户信息获取失败,状态码: %d", resp.StatusCode)
+	}
+
+	var userResp struct {
+		Data *User `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&userResp); err != nil {
+		return nil, err
+	}
+	if userResp.Data == nil || userResp.Data.ID == 0 {
+		return nil, ErrSessionExpired
+	}
+
+	return userResp.Data, nil
+}
+
+// ValidateSession 校验 Cookie 是否有效, bookURL 不为空时同时检查能否访问该知识库
+func ValidateSession(cookie, bookURL string, config Config) *SessionInfo {
+	fetcher := NewFetcher(cookie, config)
+	fetcher.setBaseURL(resolveBaseURL(config.BaseURL, bookURL))
+
+	info := &SessionInfo{}
+	user, err := fetcher.FetchCurrentUser()
+	if err != nil {
+		if !errors.Is(err, ErrSessionExpired) {
+			info.Error = fmt.Sprintf("校验登录状态失败: %v", err)
+			return info
+		}
+		// 未登录时仍可检查公开知识库
+		info.Error = err.Error()
+	} else {
+		info.LoggedIn = true
+		info.User = user
+	}
+
+	if bookURL == "" {
+		return info
+	}
+	info.BookChecked = true
+	data, err := fetcher.FetchBookData(bookURL)
+	if err != nil {
+		info.Error = fmt.Sprintf("无法访问知识库: %v", err)
+		return info
+	}
+	info.BookAccessible = data.Book.ID != 0
+	info.BookTitle = data.Book.Name
+	info.BookDocs = len(data.Book.TOC)
+	if !info.BookAccessible {
+		info.Error = "无法访问知识库"
+	}
+	return info
+}
+
+// waitForCredentials 登录失效时暂停下载, 等待 Resume 提供新的 Cookie, 任务取消时返回 false
+func (s *Spider) waitForCredentials(ctx context.Context, progress *DownloadProgress) bool {
+	progress.Status = "paused"
+	progress.Error = ErrSessionExpired.Error()
+	s.logger.Warn("登录已失效, 等待新的凭据")
+	// 先标记为等待中再通知前端, 收到通知后调用 Resume 一定能送达
+	s.mu.Lock()
+	s.paused = true
+	s.mu.Unlock()
+	s.notifyProgress(*progress)
+
+	select {
+	case <-ctx.Done():
+		s.mu.Lock()
+		s.paused = false
+		// 丢弃取消前刚送达的凭据
+		select {
+		case <-s.resume:
+		default:
+		}
+		s.mu.Unlock()
+		return false
+	case cookie := <-s.resume:
+		s.downloader.fetcher.setCookie(cookie)
+		s.logger.Info("已更新凭据, 继续下载")
+		progress.Status = "downloading"
+		progress.Error = ""
+		s.notifyProgress(*progress)
+		return true
+	}
+}
+
+// Resume 使用新的 Cookie 继续因登录失效而暂停的下载
+func (s *Spider) Resume(cookie string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.paused {
+		return fmt.Errorf("任务未在等待新的凭据")
+	}
+	// 缓冲为 1, 每次暂停只接收一次
+	s.paused = false
+	s.resume <- cookie
+	return nil
+}
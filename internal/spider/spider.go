@@ -2,12 +2,13 @@ package spider
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"math/rand"
-	"net/url"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -16,26 +17,64 @@ type Spider struct {
 	downloader       *Downloader
 	progressCallback func(DownloadProgress)
 	config           Config
+	// resume 登录失效暂停后接收新的 Cookie
+	resume chan string
+	// mu 保护 paused 与 changes, 它们会被前端调用读写
+	mu sync.Mutex
+	// paused 正在等待新的凭据
+	paused bool
+	// changes 最近一次同步的变更报告
+	changes *ChangeReport
+	logger  *slog.Logger
+	// meta 获取知识库信息使用的 Fetcher, 与下载器分别统计流量
+	meta *Fetcher
+	// docsStart, docsEnd 文档下载阶段的起止时间, 用于计算下载速度
+	docsStart time.Time
+	docsEnd   time.Time
+	// 最近一次发送的进度, 用于限制进度通知频率
+	lastNotify time.Time
+	lastStatus string
+	lastPhase  string
 }
 
+// progressInterval 同一阶段内两次进度通知的最小间隔
+const progressInterval = 250 * time.Millisecond
+
 // NewSpider 创建新的爬虫
 func NewSpider(cookie, outputPath string, config Config, progressCallback func(DownloadProgress)) *Spider {
 	return &Spider{
 		downloader:       NewDownloader(cookie, outputPath, config),
 		progressCallback: progressCallback,
 		config:           config,
+		resume:           make(chan string, 1),
+		logger:           slog.Default(),
 	}
 }
 
+// SetLogger 设置日志记录器, 调用方可附加任务 ID 等字段
+func (s *Spider) SetLogger(logger *slog.Logger) {
+	s.logger = logger
+	s.downloader.logger = logger
+}
+
 // Download 下载知识库
 func (s *Spider) Download(ctx context.Context, task DownloadTask) error {
 	progress := DownloadProgress{
-		Status:    "downloading",
-		StartTime: time.Now(),
+		Status:     "downloading",
+		Phase:      PhaseMetadata,
+		StartTime:  time.Now(),
+		ETASeconds: -1,
 	}
 
+	s.logger.Info("开始下载", "url", task.URL, "incremental", s.config.Incremental)
+
 	// 获取知识库标题
 	fetcher := NewFetcher(task.Cookie, task.Config)
+	s.meta = fetcher
+	fetcher.setContext(ctx)
+	s.downloader.fetcher.setContext(ctx)
+	fetcher.setBaseURL(resolveBaseURL(s.config.BaseURL, task.URL))
+	s.downloader.fetcher.setBaseURL(fetcher.baseURL)
 	bookTitle, err := fetcher.FetchBookTitle(task.URL)
 	if err != nil {
 		progress.Status = "error"
@@ -75,20 +114,61 @@ func (s *Spider) Download(ctx context.Context, task DownloadTask) error {
 	}
 
 	s.downloader.outputPath = bookDir
+	progress.BookDir = bookDir
+
+	// 之后的日志都带上知识库信息
+	s.logger = s.logger.With("book", displayTitle, "bookId", yuqueData.Book.ID)
+	s.downloader.logger = s.logger
+	s.logger.Info("已获取知识库", "dir", bookDir, "nodes", len(yuqueData.Book.TOC))
+
+	// 在覆盖文件之前读取上次同步的结果
+	previous := loadSnapshot(bookDir)
 
 	// 构建目录树
-	tocTree := s.buildTOCTree(yuqueData.Book.TOC)
-	progress.TotalDocs = len(yuqueData.Book.TOC)
+	book := newBookTree(yuqueData.Book, displayTitle, task.URL, s.config)
+	s.downloader.names = book.names
+	for _, item := range book.Items {
+		if item.IsDoc() {
+			progress.TotalDocs++
+		}
+	}
 	s.notifyProgress(progress)
 
-	// 生成 SUMMARY.md 内容
-	var summaryBuilder strings.Builder
+	// 增量同步: 获取文档列表比较更新时间, 失败时退回完整同步
+	var incremental *incrementalSync
+	if s.config.Incremental && previous != nil {
+		if docs, err := fetcher.FetchDocList(yuqueData.Book.ID); err == nil {
+			incremental = newIncrementalSync(previous, docs)
+		} else {
+			s.logger.Warn("获取文档列表失败, 进行完整同步", "err", err)
+		}
+	}
+
+	// 下载所有文档, 图片进度由下载器回调更新
+	progress.Phase = PhaseDocs
+	s.docsStart = time.Now()
+	s.downloader.onImages = func(found, done int) {
+		progress.Phase = PhaseAssets
+		progress.ImagesTotal += found
+		progress.ImagesDone += done
+		s.notifyProgress(progress)
+	}
+	defer func() { s.downloader.onImages = nil }()
+
+	for _, item := range book.Items {
+		node := item.Node
 
-	// 下载所有文档
-	for _, node := range yuqueData.Book.TOC {
 		// 检查是否被取消
 		select {
 		case <-ctx.Done():
+			// 保留已下载部分的索引与清单, 尚未处理的文档沿用上次的记录
+			carryForward(bookDir, previous, book)
+			if err := s.writeBookIndexes(bookDir, book); err != nil {
+				s.logger.Error("保存索引失败", "err", err)
+			}
+			if _, err := writeManifest(bookDir, book); err != nil {
+				s.logger.Error("保存下载清单失败", "file", ManifestFileName, "err", err)
+			}
 			progress.Status = "cancelled"
 			progress.Error = "下载已取消"
 			s.notifyProgress(progress)
@@ -99,67 +179,132 @@ func (s *Spider) Download(ctx context.Context, task DownloadTask) error {
 		progress.CurrentDoc = node.Title
 		s.notifyProgress(progress)
 
-		// 构建路径
-		nodePath := tocTree[node.UUID]
+		if item.Dir != "" {
+			// 目录节点, 创建目录
+			dirPath := filepath.Join(bookDir, filepath.FromSlash(item.Dir))
+			os.MkdirAll(dirPath, 0755)
+		}
+
+		if item.IsDoc() && node.Type != TOCTypeLink && incremental != nil && incremental.reuse(bookDir, item) {
+			s.logger.Debug("文档未更新, 跳过", "doc", node.Title, "path", item.DocPath)
+			progress.FinishedDocs++
+			progress.SkippedDocs++
+			s.notifyProgress(progress)
+			continue
+		}
 
-		if node.Type == "TITLE" || node.ChildUUID != "" {
-			// 目录节点
-			if strings.HasSuffix(nodePath, "/") {
-				summaryBuilder.WriteString(fmt.Sprintf("## %s\n", strings.TrimSuffix(nodePath, "/")))
+		if item.IsDoc() {
+			// 保存文档, 外链节点仅保存链接
+			var saved *SavedDocument
+			if node.Type == TOCTypeLink {
+				saved, err = s.downloader.SaveLink(node.Title, node.URL, item.DocPath)
 			} else {
-				indent := strings.Repeat("  ", strings.Count(nodePath, "/")-1)
-				lastPart := nodePath[strings.LastIndex(nodePath, "/")+1:]
-				summaryBuilder.WriteString(fmt.Sprintf("%s* %s\n", indent, lastPart))
+				// 登录失效时暂停, 获得新的 Cookie 后重试当前文档
+				for {
+					saved, err = s.downloader.SaveDocument(yuqueData.Book.ID, node.URL, item.DocPath)
+					if !errors.Is(err, ErrSessionExpired) || !s.waitForCredentials(ctx, &progress) {
+						break
+					}
+				}
+			}
+			progress.Phase = PhaseDocs
+			if err != nil && ctx.Err() != nil {
+				// 暂停期间任务被取消, 由下一轮循环处理
+				continue
+			}
+			if err != nil {
+				s.logger.Error("下载文档失败", "doc", node.Title, "slug", node.URL, "err", err)
+				progress.Failures = append(progress.Failures, DocFailure{Title: node.Title, Slug: node.URL, Error: err.Error()})
+				s.notifyProgress(progress)
+				continue
 			}
+			item.Saved = true
+			item.DocPath = saved.Path
+			item.Doc = saved.Doc
+			item.Assets = saved.Assets
+			item.CommentsPath = saved.CommentsPath
+			item.HistoryPath = saved.HistoryPath
+			item.SavedAt = time.Now()
+			s.logger.Debug("文档已保存", "doc", node.Title, "path", saved.Path, "assets", len(saved.Assets))
 
-			// 创建目录
-			dirPath := filepath.Join(bookDir, nodePath)
-			os.MkdirAll(dirPath, 0755)
+			progress.FinishedDocs++
+			s.notifyProgress(progress)
 		}
+	}
 
-		if node.URL != "" {
-			// 文档节点
-			var parentPath string
-			if node.ParentUUID != "" {
-				parentPath = tocTree[node.ParentUUID]
-			}
+	// 下载失败的文档沿用上次同步的文件
+	carryForward(bookDir, previous, book)
 
-			// 保存文档
-			if err := s.downloader.SaveDocument(yuqueData.Book.ID, node.URL, node.Title, parentPath); err != nil {
-				fmt.Printf("下载文档失败 %s: %v\n", node.Title, err)
-				continue
-			}
+	// 后处理: 索引、格式转换、清单、变更报告与 git 提交
+	progress.Phase = PhasePostProcessing
+	progress.CurrentDoc = ""
+	s.docsEnd = time.Now()
+	s.notifyProgress(progress)
 
-			// 添加到 SUMMARY
-			indent := strings.Repeat("  ", strings.Count(parentPath, "/"))
-			encodedPath := url.PathEscape(filepath.Join(parentPath, cleanFileName(node.Title)+".md"))
-			summaryBuilder.WriteString(fmt.Sprintf("%s* [%s](%s)\n", indent, node.Title, encodedPath))
+	// 生成索引
+	if err := s.writeBookIndexes(bookDir, book); err != nil {
+		progress.Status = "error"
+		progress.Error = err.Error()
+		s.notifyProgress(progress)
+		return err
+	}
 
-			progress.FinishedDocs++
-			if progress.TotalDocs > 0 {
-				progress.Percentage = float64(progress.FinishedDocs) / float64(progress.TotalDocs) * 100
-			}
+	// 转换为 Obsidian 仓库
+	if s.config.ExportFormat == ExportFormatObsidian {
+		if err := writeObsidianVault(bookDir, book); err != nil {
+			progress.Status = "error"
+			progress.Error = fmt.Sprintf("生成 Obsidian 仓库失败: %v", err)
 			s.notifyProgress(progress)
+			return err
+		}
+	}
 
-			// 随机延迟
-			minDelay := s.config.DelayMin
-			maxDelay := s.config.DelayMax
-			if maxDelay > minDelay {
-				delay := rand.Intn(maxDelay-minDelay) + minDelay
-				time.Sleep(time.Duration(delay) * time.Second)
-			}
+	// 生成静态站点项目布局
+	if len(s.config.Layouts) > 0 {
+		if err := writeLayouts(bookDir, book, s.config.Layouts); err != nil {
+			progress.Status = "error"
+			progress.Error = err.Error()
+			s.notifyProgress(progress)
+			return err
 		}
 	}
 
-	// 保存 SUMMARY.md
-	summaryPath := filepath.Join(bookDir, "SUMMARY.md")
-	if err := os.WriteFile(summaryPath, []byte(summaryBuilder.String()), 0644); err != nil {
+	// 保存下载清单, 放在最后以便记录后处理完成后的文件校验和
+	manifest, err := writeManifest(bookDir, book)
+	if err != nil {
 		progress.Status = "error"
-		progress.Error = fmt.Sprintf("保存 SUMMARY.md 失败: %v", err)
+		progress.Error = fmt.Sprintf("保存 %s 失败: %v", ManifestFileName, err)
 		s.notifyProgress(progress)
 		return err
 	}
 
+	// 与上次同步比较生成变更报告
+	if previous != nil {
+		changes := compareSnapshot(bookDir, previous, manifest)
+		s.mu.Lock()
+		s.changes = changes
+		s.mu.Unlock()
+		// 删除已删除或重命名文档留下的旧文件, 使目录与变更报告一致
+		if removed, err := pruneStaleFiles(bookDir, previous.manifest, manifest); err != nil {
+			s.logger.Error("清理过期文件失败", "err", err)
+		} else if len(removed) > 0 {
+			s.logger.Info("已清理过期文件", "count", len(removed))
+		}
+		if err := writeChangeReport(bookDir, changes); err != nil {
+			s.logger.Error("保存变更报告失败", "file", ChangeReportFileName, "err", err)
+		}
+	}
+
+	// 提交到本地 git 仓库
+	if s.config.GitCommit {
+		if err := commitBookDir(bookDir, book, s.ChangeReport()); err != nil {
+			progress.Status = "error"
+			progress.Error = err.Error()
+			s.notifyProgress(progress)
+			return err
+		}
+	}
+
 	progress.Status = "completed"
 	s.notifyProgress(progress)
 
@@ -175,7 +320,7 @@ func resolveBookFolderName(displayTitle, fallbackTitle string, bookID int) strin
 			continue
 		}
 
-		cleaned := cleanFileName(trimmed)
+		cleaned := safeFileName(trimmed)
 		cleaned = strings.Trim(cleaned, " _-")
 		if cleaned != "" {
 			return cleaned
@@ -185,55 +330,80 @@ func resolveBookFolderName(displayTitle, fallbackTitle string, bookID int) strin
 	return fmt.Sprintf("yuque-book-%d", bookID)
 }
 
-// buildTOCTree 构建目录树
-func (s *Spider) buildTOCTree(toc []TOCNode) map[string]string {
-	tree := make(map[string]string)
-	nodeMap := make(map[string]*TOCNode)
+// writeBookIndexes 写入索引文件, 任务中途取消时也会调用
+func (s *Spider) writeBookIndexes(bookDir string, book *BookTree) error {
+	return writeIndexes(bookDir, book, s.config.IndexFormats)
+}
 
-	// 构建节点映射
-	for i := range toc {
-		node := &toc[i]
-		nodeMap[node.UUID] = node
-		tree[node.UUID] = ""
+// notifyProgress 通知进度
+func (s *Spider) notifyProgress(progress DownloadProgress) {
+	switch progress.Status {
+	case "completed":
+		s.logger.Info("下载完成", "docs", progress.FinishedDocs, "skipped", progress.SkippedDocs,
+			"failed", len(progress.Failures), "elapsed", time.Since(progress.StartTime).Round(time.Second).String())
+	case "cancelled":
+		s.logger.Info("下载已取消", "docs", progress.FinishedDocs)
+	case "error":
+		s.logger.Error("下载失败", "err", progress.Error)
 	}
 
-	// 构建路径
-	for _, node := range toc {
-		if node.Type == "TITLE" || node.ChildUUID != "" {
-			path := s.buildNodePath(node.UUID, nodeMap)
-			tree[node.UUID] = path
-		}
+	// 状态或阶段变化时立即通知, 同一阶段内限制频率; 文档与图片阶段交替频繁, 视为同一阶段
+	now := time.Now()
+	inDocs := func(phase string) bool { return phase == PhaseDocs || phase == PhaseAssets }
+	changed := progress.Status != s.lastStatus ||
+		(progress.Phase != s.lastPhase && !(inDocs(progress.Phase) && inDocs(s.lastPhase)))
+	if !changed && now.Sub(s.lastNotify) < progressInterval {
+		return
 	}
+	s.lastNotify, s.lastStatus, s.lastPhase = now, progress.Status, progress.Phase
 
-	return tree
+	s.fillProgress(&progress, now)
+	if s.progressCallback != nil {
+		s.progressCallback(progress)
+	}
 }
 
-// buildNodePath 构建节点路径
-func (s *Spider) buildNodePath(uuid string, nodeMap map[string]*TOCNode) string {
-	node := nodeMap[uuid]
-	if node == nil {
-		return ""
+// fillProgress 计算百分比、流量、下载速度和预计剩余时间
+func (s *Spider) fillProgress(progress *DownloadProgress, now time.Time) {
+	processed := progress.FinishedDocs + len(progress.Failures)
+	if progress.TotalDocs > 0 {
+		progress.Percentage = float64(processed) / float64(progress.TotalDocs) * 100
+	}
+	if progress.Status == "completed" {
+		progress.Percentage = 100
 	}
 
-	parts := []string{cleanFileName(node.Title)}
+	progress.BytesDownloaded = s.downloader.fetcher.BytesReceived()
+	if s.meta != nil {
+		progress.BytesDownloaded += s.meta.BytesReceived()
+	}
 
-	// 递归构建父路径
-	current := node
-	for current.ParentUUID != "" {
-		parent := nodeMap[current.ParentUUID]
-		if parent == nil {
-			break
-		}
-		parts = append([]string{cleanFileName(parent.Title)}, parts...)
-		current = parent
+	// 跳过的文档几乎不耗时, 只按实际下载的文档计算速度
+	progress.ETASeconds = -1
+	downloaded := progress.FinishedDocs - progress.SkippedDocs + len(progress.Failures)
+	if s.docsStart.IsZero() || downloaded <= 0 {
+		return
+	}
+	end := now
+	if !s.docsEnd.IsZero() {
+		end = s.docsEnd
+	}
+	elapsed := end.Sub(s.docsStart)
+	if elapsed <= 0 {
+		return
 	}
+	progress.DocsPerMinute = float64(downloaded) / elapsed.Minutes()
 
-	return strings.Join(parts, "/") + "/"
+	// 后处理阶段耗时与文档数无关, 不做估计
+	if progress.Status == "downloading" && (progress.Phase == PhaseDocs || progress.Phase == PhaseAssets) {
+		remaining := progress.TotalDocs - processed
+		progress.ETASeconds = int64(float64(remaining) / progress.DocsPerMinute * 60)
+	}
 }
 
-// notifyProgress 通知进度
-func (s *Spider) notifyProgress(progress DownloadProgress) {
-	if s.progressCallback != nil {
-		s.progressCallback(progress)
-	}
+// ChangeReport 返回最近一次同步与上次同步之间的变更报告, 首次同步时为 nil
+func (s *Spider) ChangeReport() *ChangeReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.changes
 }
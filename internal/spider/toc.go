@@ -0,0 +1,173 @@
+package spider
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// TOCItem 目录树中的节点
+type TOCItem struct {
+	Node TOCNode
+	// Title 节点标题
+	Title string
+	// Dir 子节点所在目录(相对知识库根目录, 以 / 结尾), 叶子节点为空
+	Dir string
+	// DocPath 文档文件路径(相对知识库根目录), 非文档节点为空
+	DocPath string
+	// Saved 文档是否已成功保存
+	Saved bool
+	// Doc 已保存文档的元数据(不含正文)
+	Doc *DocData
+	// Assets 文档引用的已下载资源
+	Assets []Asset
+	// CommentsPath 评论旁路文件路径
+	CommentsPath string
+	// HistoryPath 历史版本目录
+	HistoryPath string
+	// Reused 增量同步中沿用上次下载结果, 文件未重新写入
+	Reused bool
+	// SavedAt 文档保存时间
+	SavedAt  time.Time
+	Parent   *TOCItem
+	Children []*TOCItem
+}
+
+// IsDoc 是否为文档节点
+func (item *TOCItem) IsDoc() bool {
+	return item.Node.URL != ""
+}
+
+// IsGroup 是否为包含子节点的目录节点
+func (item *TOCItem) IsGroup() bool {
+	return item.Node.Type == "TITLE" || item.Node.ChildUUID != "" || len(item.Children) > 0
+}
+
+// Position 节点在同级中的位置(从 1 开始)
+func (item *TOCItem) Position(siblings []*TOCItem) int {
+	for i, sibling := range siblings {
+		if sibling == item {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// BookTree 按目录顺序组织的知识库结构
+type BookTree struct {
+	BookID      int
+	Title       string
+	Description string
+	// SourceURL 知识库地址
+	SourceURL string
+	// Roots 顶层节点
+	Roots []*TOCItem
+	// Items 按目录顺序排列的全部节点
+	Items []*TOCItem
+	// names 已分配的本地文件名, 下载时生成的额外文件继续在其中登记
+	names *nameRegistry
+}
+
+// docSidecarExts 文档及其旁路文件的扩展名, 规划路径时一并占用
+var docSidecarExts = []string{".md", ".comments.md", ".comments.json"}
+
+// newBookTree 根据目录节点构建知识库结构, 并按命名规则规划每个节点的本地路径
+func newBookTree(book Book, title, sourceURL string, config Config) *BookTree {
+	namer := NewNamer(config)
+	obsidian := config.ExportFormat == ExportFormatObsidian
+	tree := &BookTree{
+		BookID:      book.ID,
+		Title:       title,
+		Description: book.Description,
+		SourceURL:   strings.TrimRight(sourceURL, "/"),
+	}
+
+	byUUID := make(map[string]*TOCItem, len(book.TOC))
+	for _, node := range book.TOC {
+		item := &TOCItem{
+			Node:  node,
+			Title: node.Title,
+		}
+		byUUID[node.UUID] = item
+		tree.Items = append(tree.Items, item)
+	}
+
+	for _, item := range tree.Items {
+		parent := byUUID[item.Node.ParentUUID]
+		if item.Node.ParentUUID == "" || parent == nil {
+			tree.Roots = append(tree.Roots, item)
+			continue
+		}
+		item.Parent = parent
+		parent.Children = append(parent.Children, item)
+	}
+
+	// 同一目录下的重名节点按目录顺序依次加后缀, 保证结果稳定
+	names := newNameRegistry()
+	for _, reserved := range reservedIndexNames() {
+		names.claim("", reserved, "")
+	}
+	names.claim("", HistoryDirName, "/")
+	if obsidian {
+		names.claim("", strings.SplitN(filepath.ToSlash(attachmentDir(config)), "/", 2)[0], "/")
+	}
+	assetDirs := make(map[string]bool)
+	var assign func(items []*TOCItem, parentDir string)
+	assign = func(items []*TOCItem, parentDir string) {
+		// 每个目录下的图片目录
+		if len(items) > 0 && !assetDirs[parentDir] {
+			assetDirs[parentDir] = true
+			names.claim(parentDir, "assets", "/")
+		}
+		for i, item := range items {
+			if item.IsGroup() {
+				name := names.claim(parentDir, namer.DirName(item.Node, i+1), "/")
+				item.Dir = joinSlash(parentDir, name) + "/"
+				if obsidian {
+					// 目录内与目录同名的 folder note
+					names.claim(strings.TrimSuffix(item.Dir, "/"), name, ".md")
+				}
+			}
+			if item.IsDoc() {
+				name := names.claimAll(parentDir, namer.FileName(item.Node, i+1), docSidecarExts...)
+				item.DocPath = joinSlash(parentDir, name) + ".md"
+			}
+			assign(item.Children, item.Dir)
+		}
+	}
+	assign(tree.Roots, "")
+	tree.names = names
+
+	return tree
+}
+
+// DocURL 文档在语雀上的地址, 外链节点返回链接本身
+func (t *BookTree) DocURL(item *TOCItem) string {
+	if item.Node.Type == TOCTypeLink || t.SourceURL == "" {
+		return item.Node.URL
+	}
+	return t.SourceURL + "/" + item.Node.URL
+}
+
+// Walk 按目录顺序深度优先遍历, depth 从 0 开始
+func (t *BookTree) Walk(fn func(item *TOCItem, depth int)) {
+	var walk func(items []*TOCItem, depth int)
+	walk = func(items []*TOCItem, depth int) {
+		for _, item := range items {
+			fn(item, depth)
+			walk(item.Children, depth+1)
+		}
+	}
+	walk(t.Roots, 0)
+}
+
+// joinSlash 拼接以 / 分隔的相对路径
+func joinSlash(dir, name string) string {
+	if dir == "" {
+		return name
+	}
+	if dir[len(dir)-1] == '/' {
+		return dir + name
+	}
+	return dir + "/" + name
+}
@@ -4,9 +4,8 @@ import "time"
 
 // Config 爬虫配置
 type Config struct {
-	// DelayMin 最小延迟(秒)
+	// DelayMin, DelayMax 旧版本每篇文档之后的随机延迟(秒), 已由按站点自适应的全局限速取代, 保留以兼容已保存的配置
 	DelayMin int `json:"delayMin"`
-	// DelayMax 最大延迟(秒)
 	DelayMax int `json:"delayMax"`
 	// Timeout 请求超时时间(秒)
 	Timeout int `json:"timeout"`
@@ -14,8 +13,76 @@ type Config struct {
 	MaxRetries int `json:"maxRetries"`
 	// ConcurrentDownloads 并发下载数
 	ConcurrentDownloads int `json:"concurrentDownloads"`
+	// Layouts 额外生成的静态站点项目布局(mdbook, docusaurus, vitepress, hugo)
+	Layouts []string `json:"layouts"`
+	// ExportFormat 导出格式: markdown(默认) 或 obsidian
+	ExportFormat string `json:"exportFormat"`
+	// AttachmentDir Obsidian 附件目录(相对知识库根目录)
+	AttachmentDir string `json:"attachmentDir"`
+	// LinkFormat 外链节点的保存格式: markdown(默认) 或 url
+	LinkFormat string `json:"linkFormat"`
+	// Transforms 启用的 Markdown 后处理(math, diagrams, callouts, html-cleanup)
+	Transforms []string `json:"transforms"`
+	// KeepDiagramImages 还原绘图源码时保留渲染图片作为备用
+	KeepDiagramImages bool `json:"keepDiagramImages"`
+	// FileNameTemplate 文档文件名模板, 支持 {title} {slug} {id} {uuid} {index:03} {depth}
+	FileNameTemplate string `json:"fileNameTemplate"`
+	// DirNameTemplate 目录名模板, 占位符同 FileNameTemplate
+	DirNameTemplate string `json:"dirNameTemplate"`
+	// Transliterate 音译方式: 空(不转换)、pinyin 或 ascii
+	Transliterate string `json:"transliterate"`
+	// Lowercase 文件名转为小写
+	Lowercase bool `json:"lowercase"`
+	// KebabCase 文件名转为 kebab-case
+	KebabCase bool `json:"kebabCase"`
+	// IndexFormats 生成的索引格式(summary, readme, json, opml), 未设置时仅生成 SUMMARY.md
+	IndexFormats []string `json:"indexFormats"`
+	// Comments 评论保存方式: 空(不下载)、markdown、json 或 append(追加到正文)
+	Comments string `json:"comments"`
+	// HistoryVersions 保存的历史版本数: 0 不保存, 小于 0 保存全部, 否则保存最近 N 个
+	HistoryVersions int `json:"historyVersions"`
+	// GitCommit 同步完成后将知识库目录提交到本地 git 仓库
+	GitCommit bool `json:"gitCommit"`
+	// Incremental 增量同步: 跳过自上次同步以来未更新的文档
+	Incremental bool `json:"incremental"`
+	// BaseURL API 所在站点, 如企业空间 https://xxx.yuque.com, 为空时使用知识库地址所在的站点
+	BaseURL string `json:"baseUrl"`
+	// Proxy 代理地址, 支持 http://、https:// 和 socks5://, 为空时使用系统环境变量中的代理
+	Proxy string `json:"proxy"`
+	// CACertFile 额外信任的 CA 证书(PEM), 用于企业网络的中间人代理或私有部署
+	CACertFile string `json:"caCertFile"`
+	// ClientCertFile 客户端证书(PEM), 与 ClientKeyFile 同时设置
+	ClientCertFile string `json:"clientCertFile"`
+	// ClientKeyFile 客户端证书私钥(PEM)
+	ClientKeyFile string `json:"clientKeyFile"`
 }
 
+// 导出格式
+const (
+	ExportFormatMarkdown = "markdown"
+	ExportFormatObsidian = "obsidian"
+)
+
+// 外链保存格式
+const (
+	LinkFormatMarkdown = "markdown"
+	LinkFormatURL      = "url"
+)
+
+// 文档类型
+const (
+	DocTypeDoc   = "Doc"
+	DocTypeSheet = "Sheet"
+	DocTypeBoard = "Board"
+	DocTypeTable = "Table"
+)
+
+// DefaultBaseURL 语雀默认站点
+const DefaultBaseURL = "https://www.yuque.com"
+
+// TOCTypeLink 外链目录节点类型
+const TOCTypeLink = "LINK"
+
 // DefaultConfig 默认配置
 func DefaultConfig() Config {
 	return Config{
@@ -24,6 +91,14 @@ func DefaultConfig() Config {
 		Timeout:             30,
 		MaxRetries:          3,
 		ConcurrentDownloads: 1,
+		ExportFormat:        ExportFormatMarkdown,
+		AttachmentDir:       "attachments",
+		LinkFormat:          LinkFormatMarkdown,
+		Transforms:          TransformNames(),
+		KeepDiagramImages:   true,
+		FileNameTemplate:    DefaultNameTemplate,
+		DirNameTemplate:     DefaultNameTemplate,
+		IndexFormats:        defaultIndexFormats,
 	}
 }
 
@@ -41,6 +116,7 @@ type TOCNode struct {
 	Title      string `json:"title"`
 	URL        string `json:"url"`
 	Slug       string `json:"slug"`
+	DocID      int    `json:"doc_id"`
 	Type       string `json:"type"`
 	ParentUUID string `json:"parent_uuid"`
 	ChildUUID  string `json:"child_uuid"`
@@ -54,10 +130,21 @@ type DocResponse struct {
 
 // DocData 文档数据
 type DocData struct {
-	ID         int    `json:"id"`
-	Slug       string `json:"slug"`
-	Title      string `json:"title"`
+	ID    int    `json:"id"`
+	Slug  string `json:"slug"`
+	Title string `json:"title"`
+	// Type 文档类型: Doc, Sheet, Board, Table
+	Type string `json:"type"`
+	// Format 内容格式: lake, lakesheet, lakeboard, laketable
+	Format     string `json:"format"`
 	SourceCode string `json:"sourcecode"`
+	// Content 非 Markdown 文档的原始内容
+	Content string `json:"content"`
+	// Description 文档摘要
+	Description string `json:"description"`
+	WordCount   int    `json:"word_count"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
 }
 
 // YuqueData 页面中的数据
@@ -75,12 +162,46 @@ type DownloadTask struct {
 
 // DownloadProgress 下载进度
 type DownloadProgress struct {
-	BookTitle    string    `json:"bookTitle"`
-	CurrentDoc   string    `json:"currentDoc"`
-	TotalDocs    int       `json:"totalDocs"`
-	FinishedDocs int       `json:"finishedDocs"`
-	Status       string    `json:"status"` // downloading, completed, error, cancelled
-	Error        string    `json:"error,omitempty"`
-	StartTime    time.Time `json:"startTime"`
-	Percentage   float64   `json:"percentage"`
+	BookTitle string `json:"bookTitle"`
+	// BookDir 知识库本地目录
+	BookDir    string `json:"bookDir,omitempty"`
+	CurrentDoc string `json:"currentDoc"`
+	// TotalDocs 需要下载的文档数(含外链), 不含纯目录节点
+	TotalDocs    int `json:"totalDocs"`
+	FinishedDocs int `json:"finishedDocs"`
+	// SkippedDocs 增量同步中未更新而跳过的文档数(已计入 FinishedDocs)
+	SkippedDocs int       `json:"skippedDocs"`
+	Status      string    `json:"status"` // downloading, paused, completed, error, cancelled
+	Error       string    `json:"error,omitempty"`
+	StartTime   time.Time `json:"startTime"`
+	Percentage  float64   `json:"percentage"`
+	// Failures 下载失败的文档
+	Failures []DocFailure `json:"failures,omitempty"`
+	// Phase 当前阶段: metadata, docs, assets, post-processing
+	Phase string `json:"phase"`
+	// ImagesTotal 已发现的图片数, 随文档下载增加
+	ImagesTotal int `json:"imagesTotal"`
+	// ImagesDone 已处理的图片数(含下载失败)
+	ImagesDone int `json:"imagesDone"`
+	// BytesDownloaded 已接收的字节数
+	BytesDownloaded int64 `json:"bytesDownloaded"`
+	// DocsPerMinute 文档下载速度, 不含增量同步跳过的文档
+	DocsPerMinute float64 `json:"docsPerMinute"`
+	// ETASeconds 预计剩余时间(秒), 无法估计时为 -1
+	ETASeconds int64 `json:"etaSeconds"`
+}
+
+// 下载阶段
+const (
+	PhaseMetadata       = "metadata"
+	PhaseDocs           = "docs"
+	PhaseAssets         = "assets"
+	PhasePostProcessing = "post-processing"
+)
+
+// DocFailure 下载失败的文档
+type DocFailure struct {
+	Title string `json:"title"`
+	Slug  string `json:"slug"`
+	Error string `json:"error"`
 }
@@ -0,0 +1,417 @@
+// Package vault 加密保存账号凭据(Cookie、令牌等).
+// 主密钥优先保存在系统钥匙串中, 不可用时由用户口令通过 scrypt 派生
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zalando/go-keyring"
+	"golang.org/x/crypto/scrypt"
+)
+
+// FileName 凭据库文件名
+const FileName = "credentials.json"
+
+// 主密钥来源
+const (
+	KeySourceKeyring    = "keyring"
+	KeySourcePassphrase = "passphrase"
+)
+
+// keyringService, keyringUser 主密钥在系统钥匙串中的位置
+const (
+	keyringService = "yuque-spider-gui"
+	keyringUser    = "vault-master-key"
+)
+
+// scrypt 参数
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+	keyLen  = 32
+)
+
+// checkValue 用于校验口令是否正确的明文
+const checkValue = "yuque-spider-gui vault"
+
+// ErrLocked 凭据库尚未解锁
+var ErrLocked = errors.New("凭据库未解锁")
+
+// ErrNotFound 凭据不存在
+var ErrNotFound = errors.New("凭据不存在")
+
+// Credential 凭据的公开信息, 不含密文
+type Credential struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Status 凭据库状态
+type Status struct {
+	// KeySource 主密钥来源, 尚未初始化时为空
+	KeySource string `json:"keySource"`
+	Unlocked  bool   `json:"unlocked"`
+	// NeedsPassphrase 需要用户输入口令(初始化或解锁)
+	NeedsPassphrase bool `json:"needsPassphrase"`
+	// Error 无法读取主密钥的原因(如系统钥匙串不可用), 此时只能调用 Reset 重建凭据库
+	Error string `json:"error,omitempty"`
+}
+
+// entry 加密保存的凭据
+type entry struct {
+	Credential
+	Nonce  string `json:"nonce"`
+	Secret string `json:"secret"`
+}
+
+// file 凭据库文件格式
+type file struct {
+	Version   int    `json:"version"`
+	KeySource string `json:"keySource"`
+	Salt      string `json:"salt,omitempty"`
+	// CheckNonce, Check 加密后的校验值, 用于验证口令
+	CheckNonce string  `json:"checkNonce,omitempty"`
+	Check      string  `json:"check,omitempty"`
+	Entries    []entry `json:"entries"`
+}
+
+// Vault 凭据库
+type Vault struct {
+	mu   sync.Mutex
+	path string
+	data file
+	key  []byte
+	// keyErr 读取钥匙串中主密钥的错误
+	keyErr error
+}
+
+// Open 打开目录中的凭据库. 新建时优先使用系统钥匙串保存主密钥,
+// 钥匙串不可用时需调用 Unlock 设置口令
+func Open(dir string) (*Vault, error) {
+	v := &Vault{path: filepath.Join(dir, FileName), data: file{Version: 1}}
+
+	raw, err := os.ReadFile(v.path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(raw, &v.data); err != nil {
+			return nil, fmt.Errorf("解析凭据库失败: %w", err)
+		}
+	case os.IsNotExist(err):
+		// 新建凭据库, 尝试在钥匙串中生成主密钥
+		if err := v.initKeyring(); err != nil && !errors.Is(err, errKeyringUnavailable) {
+			return nil, err
+		}
+		return v, nil
+	default:
+		return nil, err
+	}
+
+	if v.data.KeySource == KeySourceKeyring {
+		secret, err := keyring.Get(keyringService, keyringUser)
+		if err != nil {
+			v.keyErr = fmt.Errorf("读取系统钥匙串失败: %w", err)
+			return v, v.keyErr
+		}
+		if v.key, err = hex.DecodeString(secret); err != nil || len(v.key) != keyLen {
+			v.key = nil
+			v.keyErr = fmt.Errorf("系统钥匙串中的密钥无效")
+			return v, v.keyErr
+		}
+	}
+	return v, nil
+}
+
+// errKeyringUnavailable 系统钥匙串不可用, 需改用口令
+var errKeyringUnavailable = errors.New("系统钥匙串不可用")
+
+// initKeyring 生成主密钥保存到系统钥匙串并写入空凭据库, 调用方需持有锁
+func (v *Vault) initKeyring() error {
+	key := make([]byte, keyLen)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	if err := keyring.Set(keyringService, keyringUser, hex.EncodeToString(key)); err != nil {
+		return fmt.Errorf("%w: %v", errKeyringUnavailable, err)
+	}
+	v.data.KeySource = KeySourceKeyring
+	v.key = key
+	return v.save()
+}
+
+// initPassphrase 以口令派生主密钥并写入空凭据库, 调用方需持有锁
+func (v *Vault) initPassphrase(passphrase string) error {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, keyLen)
+	if err != nil {
+		return err
+	}
+	nonce, check, err := seal(key, []byte(checkValue), nil)
+	if err != nil {
+		return err
+	}
+	v.data.KeySource = KeySourcePassphrase
+	v.data.Salt = base64.StdEncoding.EncodeToString(salt)
+	v.data.CheckNonce, v.data.Check = nonce, check
+	v.key = key
+	return v.save()
+}
+
+// Reset 丢弃全部凭据并重建凭据库. passphrase 非空时改用口令模式,
+// 为空时重新在系统钥匙串中生成主密钥. 用于钥匙串丢失或无法访问导致凭据库无法解锁的情况
+func (v *Vault) Reset(passphrase string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.data = file{Version: 1, Entries: []entry{}}
+	v.key = nil
+	v.keyErr = nil
+	if passphrase != "" {
+		return v.initPassphrase(passphrase)
+	}
+	if err := v.initKeyring(); err != nil {
+		// 钥匙串仍不可用时保持未初始化状态, 等待设置口令
+		if saveErr := v.save(); saveErr != nil {
+			return saveErr
+		}
+		return err
+	}
+	return nil
+}
+
+// Status 返回凭据库状态
+func (v *Vault) Status() Status {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	status := Status{
+		KeySource:       v.data.KeySource,
+		Unlocked:        v.key != nil,
+		NeedsPassphrase: v.key == nil && v.data.KeySource != KeySourceKeyring,
+	}
+	if v.keyErr != nil {
+		status.Error = v.keyErr.Error()
+	}
+	return status
+}
+
+// Unlock 以口令解锁凭据库, 尚未初始化时以该口令创建
+func (v *Vault) Unlock(passphrase string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if passphrase == "" {
+		return fmt.Errorf("口令不能为空")
+	}
+	if v.data.KeySource == KeySourceKeyring {
+		if v.keyErr != nil {
+			return fmt.Errorf("%w, 可以重置凭据库并改用口令", v.keyErr)
+		}
+		return fmt.Errorf("凭据库使用系统钥匙串, 无需口令")
+	}
+
+	if v.data.KeySource == "" {
+		return v.initPassphrase(passphrase)
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(v.data.Salt)
+	if err != nil {
+		return fmt.Errorf("凭据库已损坏: %w", err)
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, keyLen)
+	if err != nil {
+		return err
+	}
+	if plain, err := open(key, v.data.CheckNonce, v.data.Check, nil); err != nil || string(plain) != checkValue {
+		return fmt.Errorf("口令错误")
+	}
+	v.key = key
+	return nil
+}
+
+// Lock 清除内存中的主密钥, 仅口令模式有效
+func (v *Vault) Lock() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.data.KeySource == KeySourcePassphrase {
+		v.key = nil
+	}
+}
+
+// List 列出全部凭据, 按名称排序
+func (v *Vault) List() []Credential {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	list := make([]Credential, 0, len(v.data.Entries))
+	for _, e := range v.data.Entries {
+		list = append(list, e.Credential)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Put 保存凭据, id 为空时新建, 返回凭据 ID
+func (v *Vault) Put(id, name, secret string) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.key == nil {
+		return "", ErrLocked
+	}
+	now := time.Now()
+	index := -1
+	if id == "" {
+		buf := make([]byte, 8)
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		id = "cred_" + hex.EncodeToString(buf)
+		v.data.Entries = append(v.data.Entries, entry{Credential: Credential{ID: id, CreatedAt: now}})
+		index = len(v.data.Entries) - 1
+	} else {
+		for i := range v.data.Entries {
+			if v.data.Entries[i].ID == id {
+				index = i
+			}
+		}
+		if index < 0 {
+			return "", ErrNotFound
+		}
+	}
+
+	e := &v.data.Entries[index]
+	e.Name = name
+	e.UpdatedAt = now
+	// 密文与 ID 绑定, 防止条目之间互换
+	nonce, sealed, err := seal(v.key, []byte(secret), []byte(id))
+	if err != nil {
+		return "", err
+	}
+	e.Nonce, e.Secret = nonce, sealed
+	return id, v.save()
+}
+
+// Rename 修改凭据名称
+func (v *Vault) Rename(id, name string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for i := range v.data.Entries {
+		if v.data.Entries[i].ID == id {
+			v.data.Entries[i].Name = name
+			v.data.Entries[i].UpdatedAt = time.Now()
+			return v.save()
+		}
+	}
+	return ErrNotFound
+}
+
+// Get 解密凭据内容
+func (v *Vault) Get(id string) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.key == nil {
+		return "", ErrLocked
+	}
+	for _, e := range v.data.Entries {
+		if e.ID == id {
+			plain, err := open(v.key, e.Nonce, e.Secret, []byte(id))
+			if err != nil {
+				return "", fmt.Errorf("解密凭据失败: %w", err)
+			}
+			return string(plain), nil
+		}
+	}
+	return "", ErrNotFound
+}
+
+// Delete 删除凭据
+func (v *Vault) Delete(id string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for i, e := range v.data.Entries {
+		if e.ID == id {
+			v.data.Entries = append(v.data.Entries[:i], v.data.Entries[i+1:]...)
+			return v.save()
+		}
+	}
+	return ErrNotFound
+}
+
+// save 写入凭据库文件, 调用方需持有锁
+func (v *Vault) save() error {
+	raw, err := json.MarshalIndent(v.data, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(v.path), 0700); err != nil {
+		return err
+	}
+	// 先写临时文件再替换, 避免写入中断损坏凭据库
+	tmp := v.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, v.path)
+}
+
+// seal 使用 AES-256-GCM 加密, 返回 base64 编码的 nonce 与密文
+func seal(key, plain, aad []byte) (string, string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", "", err
+	}
+	sealed := gcm.Seal(nil, nonce, plain, aad)
+	return base64.StdEncoding.EncodeToString(nonce), base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// open 解密 seal 的结果
+func open(key []byte, nonceText, sealedText string, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := base64.StdEncoding.DecodeString(nonceText)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(sealedText)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("nonce 长度无效")
+	}
+	return gcm.Open(nil, nonce, sealed, aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,530 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// 任务日志参数
+const (
+	taskLogDir = "logs"
+	// taskLogMaxSize 单个日志文件的大小上限, 超过后轮转
+	taskLogMaxSize = 5 << 20
+	// taskLogBackups 保留的轮转文件数
+	taskLogBackups = 3
+	// taskLogMaxAge 启动时清理超过该时间未更新的日志
+	taskLogMaxAge = 30 * 24 * time.Hour
+)
+
+// LogEntry 一条任务日志
+type LogEntry struct {
+	TaskID  string         `json:"taskId,omitempty"`
+	Time    time.Time      `json:"time"`
+	Level   string         `json:"level"`
+	Message string         `json:"message"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+}
+
+// String 导出用的单行文本
+func (e LogEntry) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %-5s %s", e.Time.Format("2006-01-02 15:04:05.000"), e.Level, e.Message)
+	keys := make([]string, 0, len(e.Attrs))
+	for key := range e.Attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&b, " %s=%v", key, e.Attrs[key])
+	}
+	return b.String()
+}
+
+// LogFileInfo 日志目录中的任务日志文件
+type LogFileInfo struct {
+	Name string `json:"name"`
+	// Size 包括轮转文件在内的总大小
+	Size      int64     `json:"size"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ListTaskLogs 列出日志目录中的任务日志, 包括重启前已结束任务的日志, 按更新时间从新到旧
+func (a *App) ListTaskLogs() ([]LogFileInfo, error) {
+	dir, err := taskLogDirPath()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []LogFileInfo{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取日志目录失败: %w", err)
+	}
+
+	files := make(map[string]*LogFileInfo)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() {
+			continue
+		}
+		// 轮转文件 xxx.log.N 计入对应的日志
+		name := entry.Name()
+		if i := strings.LastIndex(name, ".log"); i > 0 {
+			name = name[:i+len(".log")]
+		} else {
+			continue
+		}
+		file, ok := files[name]
+		if !ok {
+			file = &LogFileInfo{Name: name}
+			files[name] = file
+		}
+		file.Size += info.Size()
+		if info.ModTime().After(file.UpdatedAt) {
+			file.UpdatedAt = info.ModTime()
+		}
+	}
+
+	list := make([]LogFileInfo, 0, len(files))
+	for _, file := range files {
+		list = append(list, *file)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].UpdatedAt.After(list[j].UpdatedAt) })
+	return list, nil
+}
+
+// GetTaskLogFile 按文件名读取任务日志, 用于查看不在任务列表中的日志
+func (a *App) GetTaskLogFile(name string) ([]LogEntry, error) {
+	path, err := taskLogFileByName(name)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := readTaskLog(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取任务日志失败: %w", err)
+	}
+	return entries, nil
+}
+
+// ExportTaskLogFile 按文件名导出任务日志, 返回保存路径, 取消选择时返回空字符串
+func (a *App) ExportTaskLogFile(name string) (string, error) {
+	entries, err := a.GetTaskLogFile(name)
+	if err != nil {
+		return "", err
+	}
+	return a.exportLogEntries(entries, name)
+}
+
+// GetTaskLog 读取任务日志, 按时间从旧到新
+func (a *App) GetTaskLog(taskID string) ([]LogEntry, error) {
+	path, err := a.taskLogPath(taskID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := readTaskLog(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取任务日志失败: %w", err)
+	}
+	return entries, nil
+}
+
+// ExportTaskLog 将任务日志导出为文本文件, 返回保存路径, 取消选择时返回空字符串
+func (a *App) ExportTaskLog(taskID string) (string, error) {
+	entries, err := a.GetTaskLog(taskID)
+	if err != nil {
+		return "", err
+	}
+	return a.exportLogEntries(entries, taskID+".log")
+}
+
+// exportLogEntries 选择保存位置后将日志写为文本文件
+func (a *App) exportLogEntries(entries []LogEntry, defaultName string) (string, error) {
+	dest, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "导出任务日志",
+		DefaultFilename: defaultName,
+		Filters: []runtime.FileFilter{
+			{DisplayName: "日志文件 (*.log)", Pattern: "*.log"},
+		},
+	})
+	if err != nil || dest == "" {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, entry := range entries {
+		b.WriteString(entry.String())
+		b.WriteByte('\n')
+	}
+	if err := os.WriteFile(dest, []byte(b.String()), 0644); err != nil {
+		return "", fmt.Errorf("导出任务日志失败: %w", err)
+	}
+	return dest, nil
+}
+
+// taskLogPath 返回任务日志文件路径
+func (a *App) taskLogPath(taskID string) (string, error) {
+	a.mu.RLock()
+	task, exists := a.tasks[taskID]
+	a.mu.RUnlock()
+	if !exists {
+		return "", fmt.Errorf("任务不存在: %s", taskID)
+	}
+	return taskLogFile(task)
+}
+
+// taskLogDirPath 任务日志目录
+func taskLogDirPath() (string, error) {
+	dir, err := appConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, taskLogDir), nil
+}
+
+// taskLogFileByName 校验文件名并返回日志目录中的路径, 不允许访问目录之外的文件
+func taskLogFileByName(name string) (string, error) {
+	if name == "" || filepath.Base(name) != name || !strings.HasSuffix(name, ".log") {
+		return "", fmt.Errorf("无效的日志文件名: %s", name)
+	}
+	dir, err := taskLogDirPath()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("日志文件不存在: %s", name)
+	}
+	return path, nil
+}
+
+// taskLogFile 任务日志文件路径. 任务 ID 在重启后会重新编号, 文件名同时包含创建时间以免混淆
+func taskLogFile(task *DownloadTaskItem) (string, error) {
+	dir, err := taskLogDirPath()
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("%s-%s.log", task.ID, task.CreatedAt.Format("20060102-150405"))
+	return filepath.Join(dir, name), nil
+}
+
+// openTaskLog 创建任务的日志记录器: 写入轮转的 JSON 日志文件, 同时以 task:log 事件发送给前端
+func (a *App) openTaskLog(task *DownloadTaskItem) (*slog.Logger, io.Closer, error) {
+	path, err := taskLogFile(task)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, nil, err
+	}
+	file, err := openRotatingFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	options := &slog.HandlerOptions{Level: slog.LevelDebug}
+	handler := fanoutHandler{
+		slog.NewJSONHandler(file, options),
+		&eventHandler{taskID: task.ID, emit: func(entry LogEntry) { a.emit("task:log", entry) }},
+	}
+	return slog.New(handler).With("task", task.ID), file, nil
+}
+
+// pruneTaskLogs 清理过期的任务日志
+func pruneTaskLogs() {
+	dir, err := appConfigDir()
+	if err != nil {
+		return
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, taskLogDir))
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() {
+			continue
+		}
+		if time.Since(info.ModTime()) > taskLogMaxAge {
+			os.Remove(filepath.Join(dir, taskLogDir, entry.Name()))
+		}
+	}
+}
+
+// readTaskLog 读取日志文件及其轮转文件
+func readTaskLog(path string) ([]LogEntry, error) {
+	entries := []LogEntry{}
+	for i := taskLogBackups; i >= 0; i-- {
+		name := path
+		if i > 0 {
+			name = fmt.Sprintf("%s.%d", path, i)
+		}
+		file, err := os.Open(name)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			if entry, ok := parseLogLine(scanner.Bytes()); ok {
+				entries = append(entries, entry)
+			}
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// parseLogLine 解析 slog JSON 日志行
+func parseLogLine(line []byte) (LogEntry, bool) {
+	var fields map[string]any
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return LogEntry{}, false
+	}
+
+	var entry LogEntry
+	if value, ok := fields[slog.TimeKey].(string); ok {
+		entry.Time, _ = time.Parse(time.RFC3339Nano, value)
+	}
+	entry.Level, _ = fields[slog.LevelKey].(string)
+	entry.Message, _ = fields[slog.MessageKey].(string)
+	entry.TaskID, _ = fields["task"].(string)
+	for _, key := range []string{slog.TimeKey, slog.LevelKey, slog.MessageKey, "task"} {
+		delete(fields, key)
+	}
+	if len(fields) > 0 {
+		entry.Attrs = make(map[string]any, len(fields))
+		flattenLogFields(entry.Attrs, "", fields)
+	}
+	return entry, true
+}
+
+// flattenLogFields 将分组字段展开为 group.key, 与 task:log 事件保持一致
+func flattenLogFields(attrs map[string]any, prefix string, fields map[string]any) {
+	for key, value := range fields {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if group, ok := value.(map[string]any); ok {
+			flattenLogFields(attrs, key, group)
+			continue
+		}
+		attrs[key] = value
+	}
+}
+
+// rotatingFile 按大小轮转的日志文件: path → path.1 → path.2 ...
+type rotatingFile struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	size int64
+}
+
+// openRotatingFile 以追加方式打开日志文件
+func openRotatingFile(path string) (*rotatingFile, error) {
+	r := &rotatingFile{path: path}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.size > 0 && r.size+int64(len(p)) > taskLogMaxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate 关闭当前文件并依次重命名轮转文件, 调用方需持有锁
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	r.file = nil
+	for i := taskLogBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	if err := os.Rename(r.path, r.path+".1"); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// fanoutHandler 将日志同时交给多个 Handler
+type fanoutHandler []slog.Handler
+
+func (h fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h fanoutHandler) Handle(ctx context.Context, record slog.Record) error {
+	var first error
+	for _, handler := range h {
+		if !handler.Enabled(ctx, record.Level) {
+			continue
+		}
+		if err := handler.Handle(ctx, record.Clone()); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (h fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(fanoutHandler, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (h fanoutHandler) WithGroup(name string) slog.Handler {
+	handlers := make(fanoutHandler, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithGroup(name)
+	}
+	return handlers
+}
+
+// eventHandler 将日志转换为 LogEntry 发送给前端, 分组字段以 . 连接
+type eventHandler struct {
+	taskID string
+	emit   func(LogEntry)
+	attrs  []slog.Attr
+	group  string
+}
+
+func (h *eventHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *eventHandler) Handle(_ context.Context, record slog.Record) error {
+	entry := LogEntry{
+		TaskID:  h.taskID,
+		Time:    record.Time,
+		Level:   record.Level.String(),
+		Message: record.Message,
+		Attrs:   make(map[string]any),
+	}
+	for _, attr := range h.attrs {
+		addLogAttr(entry.Attrs, "", attr)
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		addLogAttr(entry.Attrs, h.group, attr)
+		return true
+	})
+	delete(entry.Attrs, "task")
+	if len(entry.Attrs) == 0 {
+		entry.Attrs = nil
+	}
+	h.emit(entry)
+	return nil
+}
+
+func (h *eventHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := *h
+	next.attrs = make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	next.attrs = append(next.attrs, h.attrs...)
+	for _, attr := range attrs {
+		if h.group != "" {
+			attr.Key = h.group + "." + attr.Key
+		}
+		next.attrs = append(next.attrs, attr)
+	}
+	return &next
+}
+
+func (h *eventHandler) WithGroup(name string) slog.Handler {
+	next := *h
+	if next.group != "" {
+		name = next.group + "." + name
+	}
+	next.group = name
+	return &next
+}
+
+// addLogAttr 将字段展开写入 map
+func addLogAttr(attrs map[string]any, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	key := attr.Key
+	if prefix != "" {
+		key = prefix + "." + key
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		for _, child := range attr.Value.Group() {
+			addLogAttr(attrs, key, child)
+		}
+		return
+	}
+	switch value := attr.Value.Any().(type) {
+	case error:
+		attrs[key] = value.Error()
+	case time.Duration:
+		attrs[key] = value.String()
+	default:
+		attrs[key] = value
+	}
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"yuque-spider-gui/internal/schedule"
+	"yuque-spider-gui/internal/spider"
+)
+
+// 任务触发方式
+const (
+	TriggerManual   = "manual"
+	TriggerSchedule = "schedule"
+)
+
+// maxRunHistory 每个任务保留的运行记录数
+const maxRunHistory = 50
+
+// schedulerInterval 检查到期任务的间隔
+const schedulerInterval = 30 * time.Second
+
+// scheduledTasksFile 定时任务持久化文件名
+const scheduledTasksFile = "scheduled-tasks.json"
+
+// RunRecord 任务的一次运行记录
+type RunRecord struct {
+	Trigger      string     `json:"trigger"`
+	StartedAt    time.Time  `json:"startedAt"`
+	FinishedAt   *time.Time `json:"finishedAt,omitempty"`
+	Status       TaskStatus `json:"status"`
+	Error        string     `json:"error,omitempty"`
+	TotalDocs    int        `json:"totalDocs"`
+	FinishedDocs int        `json:"finishedDocs"`
+	SkippedDocs  int        `json:"skippedDocs"`
+}
+
+// scheduledTask 持久化的定时任务
+type scheduledTask struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// Cookie 旧版本直接保存的 Cookie, 凭据库解锁后迁移
+	Cookie       string        `json:"cookie,omitempty"`
+	CredentialID string        `json:"credentialId,omitempty"`
+	AccountID    string        `json:"accountId,omitempty"`
+	OutputPath   string        `json:"outputPath"`
+	Config       spider.Config `json:"config"`
+	Schedule     string        `json:"schedule"`
+	History      []RunRecord   `json:"history"`
+	CreatedAt    time.Time     `json:"createdAt"`
+}
+
+// appConfigDir 返回应用配置目录, 不存在时创建
+func appConfigDir() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(base, "yuque-spider-gui")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// SetTaskSchedule 设置任务的定时同步表达式, 表达式为空时取消定时
+func (a *App) SetTaskSchedule(taskID, expr string) error {
+	var nextRunAt *time.Time
+	if expr != "" {
+		sched, err := schedule.Parse(expr)
+		if err != nil {
+			return fmt.Errorf("解析定时表达式失败: %w", err)
+		}
+		next := sched.Next(time.Now())
+		if next.IsZero() {
+			return fmt.Errorf("定时表达式没有可运行的时间: %s", expr)
+		}
+		nextRunAt = &next
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	task, exists := a.tasks[taskID]
+	if !exists {
+		return fmt.Errorf("任务不存在: %s", taskID)
+	}
+
+	// 定时任务需要持久化, 直接填写的 Cookie 先转存到凭据库
+	if expr != "" {
+		if err := a.secureTaskCookie(task); err != nil {
+			return fmt.Errorf("保存 Cookie 到凭据库失败: %w", err)
+		}
+	}
+
+	task.Schedule = expr
+	task.NextRunAt = nextRunAt
+
+	if err := a.saveScheduledTasks(); err != nil {
+		return fmt.Errorf("保存定时任务失败: %w", err)
+	}
+	a.emitTaskListUpdate()
+	return nil
+}
+
+// PreviewSchedule 预览定时表达式接下来的运行时间
+func (a *App) PreviewSchedule(expr string, count int) ([]time.Time, error) {
+	sched, err := schedule.Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("解析定时表达式失败: %w", err)
+	}
+
+	times := make([]time.Time, 0, count)
+	next := time.Now()
+	for i := 0; i < count; i++ {
+		next = sched.Next(next)
+		if next.IsZero() {
+			break
+		}
+		times = append(times, next)
+	}
+	return times, nil
+}
+
+// GetTaskRunHistory 获取任务的运行记录, 最近的在前
+func (a *App) GetTaskRunHistory(taskID string) ([]RunRecord, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	task, exists := a.tasks[taskID]
+	if !exists {
+		return nil, fmt.Errorf("任务不存在: %s", taskID)
+	}
+
+	history := make([]RunRecord, 0, len(task.History))
+	for i := len(task.History) - 1; i >= 0; i-- {
+		history = append(history, task.History[i])
+	}
+	return history, nil
+}
+
+// runScheduler 定期检查并启动到期的定时任务, 直到应用退出
+func (a *App) runScheduler(ctx context.Context) {
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			a.runDueTasks(now)
+		}
+	}
+}
+
+// runDueTasks 以增量同步方式启动到期的定时任务, 正在运行的任务顺延到下一次
+func (a *App) runDueTasks(now time.Time) {
+	a.mu.Lock()
+	var due []string
+	for _, taskID := range a.taskOrder {
+		task := a.tasks[taskID]
+		if task.Schedule == "" || task.NextRunAt == nil || now.Before(*task.NextRunAt) {
+			continue
+		}
+		sched, err := schedule.Parse(task.Schedule)
+		if err != nil {
+			continue
+		}
+		next := sched.Next(now)
+		task.NextRunAt = &next
+		if next.IsZero() {
+			task.NextRunAt = nil
+		}
+		if !task.active() {
+			due = append(due, taskID)
+		}
+	}
+	a.mu.Unlock()
+
+	for _, taskID := range due {
+		if err := a.startTask(taskID, TriggerSchedule); err != nil {
+			a.failRun(taskID, TriggerSchedule, err)
+		}
+	}
+}
+
+// failRun 记录一次未能启动的运行, 在运行记录中保留失败原因
+func (a *App) failRun(taskID, trigger string, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	task, exists := a.tasks[taskID]
+	if !exists {
+		return
+	}
+
+	now := time.Now()
+	if !task.active() {
+		task.Status = TaskStatusFailed
+		task.Error = err.Error()
+		task.CompletedAt = &now
+	}
+	task.History = append(task.History, RunRecord{
+		Trigger:    trigger,
+		StartedAt:  now,
+		FinishedAt: &now,
+		Status:     TaskStatusFailed,
+		Error:      err.Error(),
+	})
+	if len(task.History) > maxRunHistory {
+		task.History = task.History[len(task.History)-maxRunHistory:]
+	}
+	if task.Schedule != "" {
+		if err := a.saveScheduledTasks(); err != nil {
+			slog.Error("保存定时任务失败", "err", err)
+		}
+	}
+	a.emit("task:update", task)
+}
+
+// beginRun 记录一次运行的开始, 调用方需持有锁
+func (a *App) beginRun(task *DownloadTaskItem, trigger string) {
+	task.History = append(task.History, RunRecord{
+		Trigger:   trigger,
+		StartedAt: *task.StartedAt,
+		Status:    TaskStatusRunning,
+	})
+	if len(task.History) > maxRunHistory {
+		task.History = task.History[len(task.History)-maxRunHistory:]
+	}
+}
+
+// finishRun 记录最近一次运行的结果, 定时任务同时持久化运行记录, 调用方需持有锁
+func (a *App) finishRun(task *DownloadTaskItem) {
+	if len(task.History) == 0 {
+		return
+	}
+	record := &task.History[len(task.History)-1]
+	if record.FinishedAt != nil {
+		return
+	}
+
+	now := time.Now()
+	record.FinishedAt = &now
+	record.Status = task.Status
+	record.Error = task.Error
+	record.TotalDocs = task.Progress.TotalDocs
+	record.FinishedDocs = task.Progress.FinishedDocs
+	record.SkippedDocs = task.Progress.SkippedDocs
+
+	if task.Schedule != "" {
+		if err := a.saveScheduledTasks(); err != nil {
+			slog.Error("保存定时任务失败", "err", err)
+		}
+	}
+}
+
+// saveScheduledTasks 将定时任务写入配置目录, 调用方需持有锁
+func (a *App) saveScheduledTasks() error {
+	dir, err := appConfigDir()
+	if err != nil {
+		return err
+	}
+
+	tasks := make([]scheduledTask, 0)
+	for _, taskID := range a.taskOrder {
+		task := a.tasks[taskID]
+		if task.Schedule == "" {
+			continue
+		}
+		tasks = append(tasks, scheduledTask{
+			ID:           task.ID,
+			URL:          task.URL,
+			Cookie:       task.Cookie,
+			CredentialID: task.CredentialID,
+			AccountID:    task.AccountID,
+			OutputPath:   task.OutputPath,
+			Config:       task.Config,
+			Schedule:     task.Schedule,
+			History:      task.History,
+			CreatedAt:    task.CreatedAt,
+		})
+	}
+
+	data, err := json.MarshalIndent(tasks, "", "  ")
+	if err != nil {
+		return err
+	}
+	// 仅当前用户可读
+	return os.WriteFile(filepath.Join(dir, scheduledTasksFile), data, 0600)
+}
+
+// loadScheduledTasks 启动时恢复定时任务, 错过的运行不补跑
+func (a *App) loadScheduledTasks() error {
+	dir, err := appConfigDir()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, scheduledTasksFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var tasks []scheduledTask
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	for _, saved := range tasks {
+		sched, err := schedule.Parse(saved.Schedule)
+		if err != nil {
+			slog.Warn("忽略无效的定时任务", "task", saved.ID, "err", err)
+			continue
+		}
+
+		task := &DownloadTaskItem{
+			ID:           saved.ID,
+			URL:          saved.URL,
+			Cookie:       saved.Cookie,
+			CredentialID: saved.CredentialID,
+			AccountID:    saved.AccountID,
+			OutputPath:   saved.OutputPath,
+			Config:       saved.Config,
+			Schedule:     saved.Schedule,
+			History:      saved.History,
+			Status:       TaskStatusPending,
+			CreatedAt:    saved.CreatedAt,
+			Progress: spider.DownloadProgress{
+				Status: "pending",
+			},
+		}
+		if next := sched.Next(now); !next.IsZero() {
+			task.NextRunAt = &next
+		}
+		// 上次退出时仍在运行的记录标记为已取消
+		if n := len(task.History); n > 0 && task.History[n-1].FinishedAt == nil {
+			task.History[n-1].Status = TaskStatusCancelled
+			task.History[n-1].FinishedAt = &now
+		}
+
+		var n int
+		if _, err := fmt.Sscanf(saved.ID, "task_%d", &n); err == nil && n > a.taskIDCounter {
+			a.taskIDCounter = n
+		}
+		a.tasks[task.ID] = task
+		a.taskOrder = append(a.taskOrder, task.ID)
+	}
+
+	// 迁移旧版本直接保存的 Cookie
+	if a.secureTaskCookies() {
+		return a.saveScheduledTasks()
+	}
+	return nil
+}
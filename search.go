@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"yuque-spider-gui/internal/search"
+)
+
+// searchIndexFile 搜索索引文件名
+const searchIndexFile = "search-index.json"
+
+// defaultSearchLimit 未指定数量时返回的搜索结果数
+const defaultSearchLimit = 50
+
+// SearchIndexResult 知识库索引更新结果, 通过 search:indexed 事件发送给前端
+type SearchIndexResult struct {
+	BookDir string `json:"bookDir"`
+	search.UpdateStats
+	Error string `json:"error,omitempty"`
+}
+
+// openSearchIndex 打开配置目录中的搜索索引, 索引损坏时重新创建
+func (a *App) openSearchIndex() {
+	dir, err := appConfigDir()
+	if err != nil {
+		slog.Error("打开搜索索引失败", "err", err)
+		return
+	}
+	path := filepath.Join(dir, searchIndexFile)
+	index, err := search.Open(path)
+	if err != nil {
+		// 索引可以从已下载的文件重建, 损坏时直接使用空索引覆盖
+		slog.Warn("打开搜索索引失败, 将重新建立", "path", path, "err", err)
+		index = search.New(path)
+	}
+	a.searchIndex = index
+}
+
+// SearchDocuments 在已下载的知识库中全文搜索, limit 不大于 0 时返回前 50 个结果
+func (a *App) SearchDocuments(query string, limit int) ([]search.Hit, error) {
+	if a.searchIndex == nil {
+		return nil, fmt.Errorf("搜索索引不可用")
+	}
+	if strings.TrimSpace(query) == "" {
+		return []search.Hit{}, nil
+	}
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	return a.searchIndex.Search(query, limit), nil
+}
+
+// ListIndexedBooks 列出已建立索引的知识库
+func (a *App) ListIndexedBooks() []search.Book {
+	if a.searchIndex == nil {
+		return []search.Book{}
+	}
+	return a.searchIndex.Books()
+}
+
+// IndexBookDirectory 为已下载的知识库目录建立或更新索引, dir 为空时弹出目录选择框
+func (a *App) IndexBookDirectory(dir string) (*SearchIndexResult, error) {
+	if a.searchIndex == nil {
+		return nil, fmt.Errorf("搜索索引不可用")
+	}
+	if dir == "" {
+		selected, err := runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{
+			Title: "选择知识库目录",
+		})
+		if err != nil || selected == "" {
+			return nil, err
+		}
+		dir = selected
+	}
+
+	stats, err := a.searchIndex.UpdateBook(dir)
+	if err != nil {
+		return nil, fmt.Errorf("建立索引失败: %w", err)
+	}
+	return &SearchIndexResult{BookDir: dir, UpdateStats: *stats}, nil
+}
+
+// RemoveIndexedBook 从搜索索引中移除知识库, 不删除本地文件
+func (a *App) RemoveIndexedBook(dir string) error {
+	if a.searchIndex == nil {
+		return fmt.Errorf("搜索索引不可用")
+	}
+	if err := a.searchIndex.RemoveBook(dir); err != nil {
+		return fmt.Errorf("移除索引失败: %w", err)
+	}
+	return nil
+}
+
+// indexBook 同步完成后增量更新知识库的索引
+func (a *App) indexBook(bookDir string) {
+	if a.searchIndex == nil || bookDir == "" {
+		return
+	}
+	result := SearchIndexResult{BookDir: bookDir}
+	stats, err := a.searchIndex.UpdateBook(bookDir)
+	if err != nil {
+		result.Error = err.Error()
+		slog.Error("更新搜索索引失败", "dir", bookDir, "err", err)
+	} else {
+		result.UpdateStats = *stats
+	}
+	a.emit("search:indexed", result)
+}